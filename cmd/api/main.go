@@ -48,6 +48,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	go appl.MaintenanceMonitor.Run(jobsCtx)
+	go appl.LocationMaintainer.Run(jobsCtx)
+	go appl.IdempotencySweeper.Run(jobsCtx)
+	go appl.AdjustmentRecoverer.Run(jobsCtx)
+
 	r := routes.SetRouter(appl)
 
 	port := os.Getenv("APP_PORT")
@@ -87,6 +94,8 @@ func main() {
 		}
 	}
 
+	stopJobs()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -0,0 +1,95 @@
+// Command webhook-replay reprocesses stored payment webhook events, e.g. after a handler bug fix or
+// for events that arrived before their payment intent was recorded.
+//
+//	webhook-replay -id <event uuid>          replay one event, even if already processed
+//	webhook-replay -pending [-since 72h]     replay every event that has not been processed yet
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+func main() {
+	id := flag.String("id", "", "replay the stored event with this id")
+	pending := flag.Bool("pending", false, "replay all unprocessed events")
+	since := flag.Duration("since", 7*24*time.Hour, "with -pending, only events received within this window")
+	limit := flag.Int("limit", 500, "with -pending, maximum number of events to replay")
+	flag.Parse()
+
+	ctx := context.Background()
+	if (*id == "") == !*pending {
+		fmt.Fprintln(os.Stderr, "exactly one of -id or -pending is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		logger.Error(ctx, "DATABASE_URL environment variable is required")
+		os.Exit(1)
+	}
+	pool, err := store.OpenPool(dsn)
+	if err != nil {
+		logger.Error(ctx, "failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	paymentStore := store.NewPostgresPaymentStore(pool)
+	eventStore := store.NewPostgresPaymentEventStore(pool)
+	processor := webhooks.NewProcessor(paymentStore, eventStore)
+
+	var events []store.PaymentEvent
+	if *id != "" {
+		eventID, err := uuid.Parse(*id)
+		if err != nil {
+			logger.Error(ctx, "invalid event id", "id", *id)
+			os.Exit(2)
+		}
+		e, err := eventStore.Get(ctx, eventID)
+		if err != nil {
+			logger.Error(ctx, "failed to load event", "id", eventID, "error", err)
+			os.Exit(1)
+		}
+		events = append(events, *e)
+	} else {
+		events, err = eventStore.List(ctx, store.PaymentEventFilter{
+			UnprocessedOnly: true,
+			Since:           time.Now().Add(-*since),
+			Limit:           *limit,
+		})
+		if err != nil {
+			logger.Error(ctx, "failed to list pending events", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	failed := 0
+	for i := range events {
+		e := &events[i]
+		ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := processor.Process(ctxTimeout, e)
+		cancel()
+		if err != nil {
+			failed++
+			logger.Error(ctx, "event replay failed", "id", e.ID, "event_id", e.EventID, "type", e.EventType, "error", err)
+			continue
+		}
+		logger.Info(ctx, "event replayed", "id", e.ID, "event_id", e.EventID, "type", e.EventType)
+	}
+
+	logger.Info(ctx, "webhook replay finished", "total", len(events), "failed", failed)
+	if failed > 0 {
+		pool.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,134 @@
+package adjustments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+// Applier performs the gateway side of processing adjustments. It is shared by the admin endpoints and
+// the recovery job, so applying the same adjustment twice must be safe: gateway calls reuse the
+// adjustment's idempotency key and Finish only accepts adjustments that are still processing.
+type Applier struct {
+	Adjustments store.AdjustmentStore
+	Payments    store.PaymentStore
+	Gateway     payments.PaymentGateway
+}
+
+func NewApplier(as store.AdjustmentStore, ps store.PaymentStore, gw payments.PaymentGateway) *Applier {
+	return &Applier{as, ps, gw}
+}
+
+// errCannotApply marks failures found before any gateway call, which can't succeed on a retry.
+var errCannotApply = errors.New("adjustment cannot be applied")
+
+// Apply calls the gateway for a processing adjustment and records the outcome. Only rejections the
+// provider classified (see rejected) mark the adjustment failed. Any other error, a timeout or a 5xx
+// for instance, leaves the outcome unknown; the adjustment is returned still processing for the
+// recovery job to retry under the same idempotency key. The error is only for bookkeeping failures
+// after the provider may already have moved money.
+func (ap *Applier) Apply(ctx context.Context, a *store.Adjustment) (*store.Adjustment, error) {
+	res, gwErr := ap.callGateway(ctx, a)
+	if gwErr != nil {
+		if !rejected(gwErr) {
+			logger.Warn(ctx, "payment adjustment outcome unknown; leaving it for recovery", "adjustment_id", a.ID, "error", gwErr)
+			return a, nil
+		}
+		res = store.AdjustmentResult{FailureReason: gwErr.Error()}
+		logger.Warn(ctx, "payment adjustment failed at provider", "adjustment_id", a.ID, "error", gwErr)
+	}
+	out, err := ap.Adjustments.Finish(ctx, a.ID, res)
+	if err != nil {
+		logger.Error(ctx, "failed to record payment adjustment outcome", "adjustment_id", a.ID, "applied", res.Applied, "provider_ref", res.ProviderRef, "error", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+// rejected reports whether err means the gateway call definitely did not move money.
+func rejected(err error) bool {
+	return errors.Is(err, errCannotApply) ||
+		errors.Is(err, payments.ErrCardDeclined) ||
+		errors.Is(err, payments.ErrInvalidRequest) ||
+		errors.Is(err, payments.ErrInvalidState) ||
+		errors.Is(err, payments.ErrNotFound)
+}
+
+func (ap *Applier) callGateway(ctx context.Context, a *store.Adjustment) (store.AdjustmentResult, error) {
+	pi, err := ap.Payments.GetIntent(ctx, a.PaymentIntentID)
+	if err != nil {
+		return store.AdjustmentResult{}, err
+	}
+	if !pi.ProviderIntentID.Valid {
+		return store.AdjustmentResult{}, fmt.Errorf("%w: payment has no provider reference", errCannotApply)
+	}
+	key := "adjustment-" + a.ID.String()
+
+	if a.Kind == store.AdjustmentRefund {
+		re, err := ap.Gateway.Refund(ctx, pi.ProviderIntentID.String, a.AmountCents, key)
+		if err != nil {
+			return store.AdjustmentResult{}, err
+		}
+		return store.AdjustmentResult{Applied: true, ProviderRef: re.ID}, nil
+	}
+
+	// Extra charges go on the card used for the original payment as a new, immediately captured intent.
+	method, err := ap.Payments.GetMethod(ctx, pi.UserID, pi.PaymentMethodID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return store.AdjustmentResult{}, fmt.Errorf("%w: the rider's card for this payment has been removed", errCannotApply)
+		}
+		return store.AdjustmentResult{}, err
+	}
+	ch, err := ap.Gateway.Authorize(ctx, payments.AuthorizeRequest{
+		CustomerID:     method.ProviderCustomerID,
+		MethodID:       method.ProviderMethodID,
+		AmountCents:    a.AmountCents,
+		Currency:       pi.Currency,
+		Description:    fmt.Sprintf("Trip adjustment: %s", a.Reason),
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		return store.AdjustmentResult{}, err
+	}
+	captured, err := ap.Gateway.Capture(ctx, ch.ID, 0, key+"-capture")
+	if err != nil {
+		if !rejected(err) {
+			return store.AdjustmentResult{}, err
+		}
+		// Don't leave the hold on the rider's card. If the void fails too, keep the adjustment
+		// processing so the next attempt releases it.
+		if _, voidErr := ap.Gateway.Void(ctx, ch.ID, key+"-void"); voidErr != nil {
+			return store.AdjustmentResult{}, fmt.Errorf("capture failed (%v) and the hold could not be released: %v", err, voidErr)
+		}
+		return store.AdjustmentResult{}, err
+	}
+	ch = captured
+
+	// A retried adjustment may already have recorded its charge before the outcome was saved.
+	if existing, err := ap.Payments.GetIntentByProviderID(ctx, pi.Provider, ch.ID); err == nil {
+		return store.AdjustmentResult{Applied: true, ProviderRef: ch.ID, ChargeIntentID: &existing.ID}, nil
+	}
+	charge, err := ap.Payments.CreateIntent(ctx, &store.PaymentIntent{
+		UserID:                pi.UserID,
+		BookingID:             pi.BookingID,
+		PaymentMethodID:       pi.PaymentMethodID,
+		Provider:              pi.Provider,
+		ProviderIntentID:      sql.NullString{String: ch.ID, Valid: true},
+		Status:                store.PaymentIntentSucceeded,
+		Currency:              pi.Currency,
+		AmountAuthorizedCents: ch.AmountCents,
+		AmountCapturedCents:   ch.AmountCapturedCents,
+	})
+	if err != nil {
+		// The money has moved; keep the provider id on the adjustment so the charge can be reconciled.
+		logger.Error(ctx, "failed to record adjustment charge", "adjustment_id", a.ID, "provider_intent_id", ch.ID, "error", err)
+		return store.AdjustmentResult{Applied: true, ProviderRef: ch.ID}, nil
+	}
+	return store.AdjustmentResult{Applied: true, ProviderRef: ch.ID, ChargeIntentID: &charge.ID}, nil
+}
@@ -0,0 +1,182 @@
+package adjustments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+// flakyGateway fails refunds or captures with a fixed error and otherwise behaves like the fake.
+type flakyGateway struct {
+	*payments.FakeGateway
+	refundErr  error
+	captureErr error
+}
+
+func (g *flakyGateway) Capture(ctx context.Context, chargeID string, amountCents int64, key string) (*payments.Charge, error) {
+	if g.captureErr != nil {
+		return nil, g.captureErr
+	}
+	return g.FakeGateway.Capture(ctx, chargeID, amountCents, key)
+}
+
+func (g *flakyGateway) Refund(ctx context.Context, chargeID string, amountCents int64, key string) (*payments.Refund, error) {
+	if g.refundErr != nil {
+		return nil, g.refundErr
+	}
+	return g.FakeGateway.Refund(ctx, chargeID, amountCents, key)
+}
+
+type fakeAdjustments struct {
+	store.AdjustmentStore
+	finished *store.AdjustmentResult
+}
+
+func (f *fakeAdjustments) Finish(_ context.Context, id uuid.UUID, res store.AdjustmentResult) (*store.Adjustment, error) {
+	f.finished = &res
+	a := &store.Adjustment{ID: id, Status: store.AdjustmentFailed, ChargeIntentID: res.ChargeIntentID}
+	if res.Applied {
+		a.Status = store.AdjustmentApplied
+	}
+	return a, nil
+}
+
+type fakePayments struct {
+	store.PaymentStore
+	intent *store.PaymentIntent
+	method *store.PaymentMethod
+}
+
+func (f *fakePayments) GetIntent(context.Context, uuid.UUID) (*store.PaymentIntent, error) {
+	return f.intent, nil
+}
+
+func (f *fakePayments) GetMethod(context.Context, uuid.UUID, uuid.UUID) (*store.PaymentMethod, error) {
+	return f.method, nil
+}
+
+func (f *fakePayments) GetIntentByProviderID(context.Context, string, string) (*store.PaymentIntent, error) {
+	return nil, store.ErrNotFound
+}
+
+func (f *fakePayments) CreateIntent(_ context.Context, pi *store.PaymentIntent) (*store.PaymentIntent, error) {
+	out := *pi
+	out.ID = uuid.New()
+	return &out, nil
+}
+
+// newTestApplier returns an applier over a fake gateway holding a captured $50 payment.
+func newTestApplier(t *testing.T) (*Applier, *flakyGateway, *fakeAdjustments) {
+	t.Helper()
+	ctx := context.Background()
+	gw := &flakyGateway{FakeGateway: payments.NewFakeGateway()}
+	card, err := gw.SaveCard(ctx, payments.SaveCardRequest{Token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("save card: %v", err)
+	}
+	ch, err := gw.Authorize(ctx, payments.AuthorizeRequest{
+		CustomerID: card.CustomerID, MethodID: card.MethodID, AmountCents: 5000, Currency: "usd", IdempotencyKey: "trip",
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if _, err := gw.FakeGateway.Capture(ctx, ch.ID, 0, "trip-capture"); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	ps := &fakePayments{
+		intent: &store.PaymentIntent{
+			ID: uuid.New(), UserID: uuid.New(), PaymentMethodID: uuid.New(), Provider: "fake",
+			ProviderIntentID: sql.NullString{String: ch.ID, Valid: true}, Status: store.PaymentIntentSucceeded,
+			Currency: "usd", AmountAuthorizedCents: 5000, AmountCapturedCents: 5000,
+		},
+		method: &store.PaymentMethod{ProviderCustomerID: card.CustomerID, ProviderMethodID: card.MethodID},
+	}
+	as := &fakeAdjustments{}
+	return NewApplier(as, ps, gw), gw, as
+}
+
+func TestApplyOutcome(t *testing.T) {
+	upstream := errors.New("stripe: unexpected status 502")
+	tests := []struct {
+		name       string
+		kind       string
+		amount     int64
+		refundErr  error
+		captureErr error
+		wantStatus string
+	}{
+		{"refund applied", store.AdjustmentRefund, 1000, nil, nil, store.AdjustmentApplied},
+		{"refund rejected", store.AdjustmentRefund, 9000, nil, nil, store.AdjustmentFailed},
+		{"refund upstream error", store.AdjustmentRefund, 1000, upstream, nil, store.AdjustmentProcessing},
+		{"charge applied", store.AdjustmentCharge, 1500, nil, nil, store.AdjustmentApplied},
+		{"charge capture rejected", store.AdjustmentCharge, 1500, nil, fmt.Errorf("%w: card expired", payments.ErrCardDeclined), store.AdjustmentFailed},
+		{"charge capture upstream error", store.AdjustmentCharge, 1500, nil, upstream, store.AdjustmentProcessing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ap, gw, as := newTestApplier(t)
+			gw.refundErr, gw.captureErr = tt.refundErr, tt.captureErr
+			a := &store.Adjustment{ID: uuid.New(), Kind: tt.kind, Reason: "toll", AmountCents: tt.amount, Status: store.AdjustmentProcessing}
+
+			out, err := ap.Apply(context.Background(), a)
+			if err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if out.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", out.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == store.AdjustmentProcessing && as.finished != nil {
+				t.Errorf("an unknown outcome was recorded: %+v", as.finished)
+			}
+		})
+	}
+}
+
+func TestApplyReleasesHoldWhenCaptureRejected(t *testing.T) {
+	ctx := context.Background()
+	ap, gw, _ := newTestApplier(t)
+	gw.captureErr = payments.ErrCardDeclined
+	a := &store.Adjustment{ID: uuid.New(), Kind: store.AdjustmentCharge, Reason: "toll", AmountCents: 1500, Status: store.AdjustmentProcessing}
+	if _, err := ap.Apply(ctx, a); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	// Replaying the authorization returns the hold Apply placed.
+	ch, err := gw.Authorize(ctx, payments.AuthorizeRequest{IdempotencyKey: "adjustment-" + a.ID.String()})
+	if err != nil {
+		t.Fatalf("replay authorize: %v", err)
+	}
+	if _, err := gw.FakeGateway.Capture(ctx, ch.ID, 0, "late-capture"); !errors.Is(err, payments.ErrInvalidState) {
+		t.Errorf("capture after void err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestApplyRetryUsesSameKey(t *testing.T) {
+	ctx := context.Background()
+	ap, gw, as := newTestApplier(t)
+	gw.captureErr = errors.New("stripe: unexpected status 503")
+	a := &store.Adjustment{ID: uuid.New(), Kind: store.AdjustmentCharge, Reason: "toll", AmountCents: 1500, Status: store.AdjustmentProcessing}
+	if out, err := ap.Apply(ctx, a); err != nil || out.Status != store.AdjustmentProcessing {
+		t.Fatalf("first apply = %v, %v; want processing", out, err)
+	}
+
+	// The recovery job retries once the provider is back; the hold from the first attempt is captured.
+	gw.captureErr = nil
+	out, err := ap.Apply(ctx, a)
+	if err != nil || out.Status != store.AdjustmentApplied {
+		t.Fatalf("retry = %v, %v; want applied", out, err)
+	}
+	first, err := gw.Authorize(ctx, payments.AuthorizeRequest{IdempotencyKey: "adjustment-" + a.ID.String()})
+	if err != nil {
+		t.Fatalf("replay authorize: %v", err)
+	}
+	if as.finished.ProviderRef != first.ID {
+		t.Errorf("provider ref = %s, want the first attempt's charge %s", as.finished.ProviderRef, first.ID)
+	}
+}
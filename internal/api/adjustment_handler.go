@@ -0,0 +1,317 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/adjustments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+type AdjustmentHandler struct {
+	AdjustmentStore store.AdjustmentStore
+	PaymentStore    store.PaymentStore
+	Applier         *adjustments.Applier
+	Provider        string
+	// ApprovalThresholdCents is the most one admin may adjust a payment by alone within
+	// ApprovalWindow; anything above it waits for a second admin.
+	ApprovalThresholdCents int64
+	ApprovalWindow         time.Duration
+}
+
+func NewAdjustmentHandler(as store.AdjustmentStore, ps store.PaymentStore, ap *adjustments.Applier, provider string, threshold int64, window time.Duration) *AdjustmentHandler {
+	return &AdjustmentHandler{as, ps, ap, provider, threshold, window}
+}
+
+func adjustmentResponse(a *store.Adjustment) map[string]any {
+	resp := map[string]any{
+		"id":                a.ID,
+		"payment_intent_id": a.PaymentIntentID,
+		"kind":              a.Kind,
+		"reason":            a.Reason,
+		"amount_cents":      a.AmountCents,
+		"status":            a.Status,
+		"requested_by":      a.RequestedBy,
+		"created_at":        a.CreatedAt,
+		"updated_at":        a.UpdatedAt,
+	}
+	if a.Note.Valid {
+		resp["note"] = a.Note.String
+	}
+	if a.DecidedBy != nil {
+		resp["decided_by"] = a.DecidedBy
+		resp["decided_at"] = a.DecidedAt.Time
+	}
+	if a.DecisionNote.Valid {
+		resp["decision_note"] = a.DecisionNote.String
+	}
+	if a.ChargeIntentID != nil {
+		resp["charge_intent_id"] = a.ChargeIntentID
+	}
+	if a.FailureReason.Valid {
+		resp["failure_reason"] = a.FailureReason.String
+	}
+	return resp
+}
+
+func respondAdjustmentError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Payment or adjustment not found"))
+	case errors.Is(err, store.ErrPaymentNotCaptured):
+		helper.RespondError(w, r, apperror.New(apperror.CodeValidationError, "Only captured payments can be adjusted", http.StatusUnprocessableEntity))
+	case errors.Is(err, store.ErrRefundExceedsCaptured):
+		helper.RespondError(w, r, apperror.New(apperror.CodeValidationError, "Refund exceeds the refundable amount", http.StatusUnprocessableEntity))
+	case errors.Is(err, store.ErrAdjustmentNotPending):
+		helper.RespondError(w, r, apperror.Conflict("Adjustment is not awaiting approval"))
+	case errors.Is(err, store.ErrSelfApproval):
+		helper.RespondError(w, r, apperror.Forbidden("Adjustment must be approved by a different admin"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+func (h *AdjustmentHandler) auditAdjustment(r *http.Request, event logger.AuditEvent, adminID uuid.UUID, a *store.Adjustment) {
+	logger.Audit(r.Context(), event, &adminID, helper.ClientIP(r), r.UserAgent(), a.Status != store.AdjustmentFailed, map[string]any{
+		"adjustment_id":     a.ID,
+		"payment_intent_id": a.PaymentIntentID,
+		"kind":              a.Kind,
+		"reason":            a.Reason,
+		"amount_cents":      a.AmountCents,
+		"status":            a.Status,
+	})
+}
+
+// HandleGetPayment returns a payment intent with its adjustments.
+func (h *AdjustmentHandler) HandleGetPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	intentID, err := helper.URLParamUUID(r, "intentID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid payment id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pi, err := h.PaymentStore.GetIntent(ctxTimeout, intentID)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to load payment")
+		return
+	}
+	list, err := h.AdjustmentStore.ListByIntent(ctxTimeout, intentID)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to list adjustments")
+		return
+	}
+	adjustments := make([]map[string]any, 0, len(list))
+	for i := range list {
+		adjustments = append(adjustments, adjustmentResponse(&list[i]))
+	}
+	resp := paymentIntentResponse(pi)
+	resp["user_id"] = pi.UserID
+	resp["adjustments"] = adjustments
+	helper.RespondJSON(w, r, http.StatusOK, resp)
+}
+
+// HandleCreate records a refund or extra charge against a payment. Adjustments that keep the payment's
+// recent total within the approval threshold are applied at once; the rest wait for a second admin.
+func (h *AdjustmentHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	intentID, err := helper.URLParamUUID(r, "intentID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid payment id"))
+		return
+	}
+
+	var body struct {
+		Kind        string `json:"kind"`
+		Reason      string `json:"reason"`
+		AmountCents int64  `json:"amount_cents"`
+		Note        string `json:"note"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse adjustment", "error", err)
+		return
+	}
+	if body.Kind != store.AdjustmentRefund && body.Kind != store.AdjustmentCharge {
+		helper.RespondError(w, r, apperror.BadRequest("kind must be refund or charge"))
+		return
+	}
+	if !store.AdjustmentReasons[body.Reason] {
+		helper.RespondError(w, r, apperror.BadRequest("reason must be one of service_issue, overcharge, goodwill, toll, parking, damage, cleaning, other"))
+		return
+	}
+	if body.AmountCents <= 0 {
+		helper.RespondError(w, r, apperror.BadRequest("amount_cents must be positive"))
+		return
+	}
+	if len(body.Note) > 1000 {
+		helper.RespondError(w, r, apperror.BadRequest("note must be at most 1000 characters"))
+		return
+	}
+
+	// Stay inside the server's write timeout so the admin hears the outcome of any money movement.
+	ctxTimeout, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	pi, err := h.PaymentStore.GetIntent(ctxTimeout, intentID)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to load payment")
+		return
+	}
+	if pi.Provider != h.Provider {
+		helper.RespondError(w, r, apperror.Conflict("Payment was taken with a different payment provider"))
+		return
+	}
+
+	a, err := h.AdjustmentStore.Create(ctxTimeout, &store.Adjustment{
+		PaymentIntentID: intentID,
+		Kind:            body.Kind,
+		Reason:          body.Reason,
+		AmountCents:     body.AmountCents,
+		Note:            sql.NullString{String: strings.TrimSpace(body.Note), Valid: strings.TrimSpace(body.Note) != ""},
+		RequestedBy:     adminID,
+	}, h.ApprovalThresholdCents, h.ApprovalWindow)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to create adjustment")
+		return
+	}
+	h.auditAdjustment(r, logger.AuditPaymentAdjustmentCreate, adminID, a)
+
+	status := http.StatusCreated
+	if a.Status == store.AdjustmentProcessing {
+		a = h.apply(ctxTimeout, r, adminID, a)
+		if a.Status == store.AdjustmentProcessing {
+			status = http.StatusAccepted
+		}
+	}
+	helper.RespondJSON(w, r, status, adjustmentResponse(a))
+}
+
+// apply runs the gateway side of a processing adjustment. When the outcome is unknown, or the gateway
+// answered but the result could not be saved, the adjustment comes back still processing and the
+// recovery job settles it. That is reported as 202 rather than an error: a 5xx would release the
+// request's idempotency key, and a retry would create a second adjustment and move money twice.
+func (h *AdjustmentHandler) apply(ctx context.Context, r *http.Request, adminID uuid.UUID, a *store.Adjustment) *store.Adjustment {
+	if applied, err := h.Applier.Apply(ctx, a); err == nil {
+		a = applied
+	}
+	h.auditAdjustment(r, logger.AuditPaymentAdjustmentApply, adminID, a)
+	return a
+}
+
+func (h *AdjustmentHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.AdjustmentPendingApproval, store.AdjustmentProcessing, store.AdjustmentApplied,
+		store.AdjustmentRejected, store.AdjustmentFailed:
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("Invalid status filter"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.AdjustmentStore.ListByStatus(ctxTimeout, status, limit, offset)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to list adjustments")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, adjustmentResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func decisionNote(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := helper.DecodeJSON(w, r, &body); err != nil {
+			helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+			return "", false
+		}
+	}
+	if len(body.Note) > 1000 {
+		helper.RespondError(w, r, apperror.BadRequest("note must be at most 1000 characters"))
+		return "", false
+	}
+	return strings.TrimSpace(body.Note), true
+}
+
+// HandleApprove is the second admin's sign-off on a large adjustment; it applies it immediately.
+func (h *AdjustmentHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "adjustmentID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid adjustment id"))
+		return
+	}
+	note, ok := decisionNote(w, r)
+	if !ok {
+		return
+	}
+
+	// Stay inside the server's write timeout so the admin hears the outcome of any money movement.
+	ctxTimeout, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	a, err := h.AdjustmentStore.Approve(ctxTimeout, id, adminID, note)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to approve adjustment")
+		return
+	}
+	h.auditAdjustment(r, logger.AuditPaymentAdjustmentApprove, adminID, a)
+
+	a = h.apply(ctxTimeout, r, adminID, a)
+	status := http.StatusOK
+	if a.Status == store.AdjustmentProcessing {
+		status = http.StatusAccepted
+	}
+	helper.RespondJSON(w, r, status, adjustmentResponse(a))
+}
+
+func (h *AdjustmentHandler) HandleReject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "adjustmentID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid adjustment id"))
+		return
+	}
+	note, ok := decisionNote(w, r)
+	if !ok {
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	a, err := h.AdjustmentStore.Reject(ctxTimeout, id, adminID, note)
+	if err != nil {
+		respondAdjustmentError(w, r, err, "Failed to reject adjustment")
+		return
+	}
+	h.auditAdjustment(r, logger.AuditPaymentAdjustmentReject, adminID, a)
+	helper.RespondJSON(w, r, http.StatusOK, adjustmentResponse(a))
+}
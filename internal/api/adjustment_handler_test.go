@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/adjustments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type stubAdjustments struct {
+	store.AdjustmentStore
+	finishErr error
+}
+
+func (s *stubAdjustments) Create(_ context.Context, a *store.Adjustment, _ int64, _ time.Duration) (*store.Adjustment, error) {
+	out := *a
+	out.ID = uuid.New()
+	out.Status = store.AdjustmentProcessing
+	return &out, nil
+}
+
+func (s *stubAdjustments) Finish(context.Context, uuid.UUID, store.AdjustmentResult) (*store.Adjustment, error) {
+	return nil, s.finishErr
+}
+
+type stubPayments struct {
+	store.PaymentStore
+	intent *store.PaymentIntent
+}
+
+func (s *stubPayments) GetIntent(context.Context, uuid.UUID) (*store.PaymentIntent, error) {
+	return s.intent, nil
+}
+
+// A refund that went through at the provider but could not be recorded must not come back as a 5xx:
+// that would release the idempotency key and let a retry refund the rider a second time.
+func TestHandleCreateAdjustmentOutcomeNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	gw := payments.NewFakeGateway()
+	card, err := gw.SaveCard(ctx, payments.SaveCardRequest{Token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("save card: %v", err)
+	}
+	ch, err := gw.Authorize(ctx, payments.AuthorizeRequest{
+		CustomerID: card.CustomerID, MethodID: card.MethodID, AmountCents: 5000, Currency: "usd", IdempotencyKey: "trip",
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if _, err := gw.Capture(ctx, ch.ID, 0, "trip-capture"); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	intentID := uuid.New()
+	as := &stubAdjustments{finishErr: errors.New("connection reset")}
+	ps := &stubPayments{intent: &store.PaymentIntent{
+		ID: intentID, Provider: "fake", ProviderIntentID: sql.NullString{String: ch.ID, Valid: true},
+		Status: store.PaymentIntentSucceeded, Currency: "usd", AmountAuthorizedCents: 5000, AmountCapturedCents: 5000,
+	}}
+	h := NewAdjustmentHandler(as, ps, adjustments.NewApplier(as, ps, gw), "fake", 10000, 24*time.Hour)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/payments/"+intentID.String()+"/adjustments",
+		strings.NewReader(`{"kind":"refund","reason":"overcharge","amount_cents":1000}`))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("intentID", intentID.String())
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rec := httptest.NewRecorder()
+	h.HandleCreate(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+	var body struct {
+		Data struct {
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Data.Status != store.AdjustmentProcessing {
+		t.Errorf("adjustment status = %q, want %s", body.Data.Status, store.AdjustmentProcessing)
+	}
+}
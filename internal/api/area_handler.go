@@ -0,0 +1,478 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/geo"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/tax"
+	"github.com/google/uuid"
+)
+
+// GeoJSON polygons are larger than ordinary request bodies, so imports get their own limit.
+const maxGeoJSONBytes = 5 << 20
+
+type AreaHandler struct {
+	AreaStore store.AreaStore
+	TaxStore  store.TaxStore
+}
+
+func NewAreaHandler(as store.AreaStore, ts store.TaxStore) *AreaHandler {
+	return &AreaHandler{as, ts}
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         any             `json:"id,omitempty"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// areaFeature renders an area as a GeoJSON Feature; import reads the same properties back.
+func areaFeature(a *store.ServiceArea) map[string]any {
+	return map[string]any{
+		"type": "Feature",
+		"id":   a.ID,
+		"geometry": map[string]any{
+			"type":        "MultiPolygon",
+			"coordinates": a.Geometry,
+		},
+		"properties": map[string]any{
+			"name":       a.Name,
+			"kind":       a.Kind,
+			"active":     a.Active,
+			"updated_at": a.UpdatedAt,
+		},
+	}
+}
+
+func zoneRateResponse(zr *store.ZoneRate) map[string]any {
+	return map[string]any{
+		"id":            zr.ID,
+		"from_zone_id":  zr.FromZoneID,
+		"to_zone_id":    zr.ToZoneID,
+		"vehicle_class": zr.VehicleClass,
+		"amount_cents":  zr.AmountCents,
+		"updated_at":    zr.UpdatedAt,
+	}
+}
+
+func respondAreaError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Area or rate not found"))
+	case errors.Is(err, store.ErrNotAZone):
+		helper.RespondError(w, r, apperror.BadRequest("Both ends of a rate must be pricing zones"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+// parseFeature turns one imported feature into an area, returning a message on invalid input.
+func parseFeature(f *geoJSONFeature) (*store.ServiceArea, string) {
+	if f.Type != "Feature" {
+		return nil, "type must be Feature"
+	}
+	name, _ := f.Properties["name"].(string)
+	if strings.TrimSpace(name) == "" || len(name) > 100 {
+		return nil, "properties.name is required and must be at most 100 characters"
+	}
+	kind, _ := f.Properties["kind"].(string)
+	if kind != store.AreaKindServiceArea && kind != store.AreaKindZone {
+		return nil, "properties.kind must be service_area or zone"
+	}
+	active := true
+	if v, ok := f.Properties["active"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, "properties.active must be a boolean"
+		}
+		active = b
+	}
+	mp, err := geo.ParseGeometry(f.Geometry)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return &store.ServiceArea{Name: name, Kind: kind, Geometry: mp, Active: active}, ""
+}
+
+// HandleImport accepts a GeoJSON Feature or FeatureCollection. Features are matched to existing areas
+// by properties.name, so re-importing an edited export updates areas in place. Every feature is validated
+// before anything is written, and the import is applied all or nothing.
+func (h *AreaHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// GeoJSON carries foreign members (bbox, crs, ...), so this can't go through DecodeJSON's strict mode.
+	r.Body = http.MaxBytesReader(w, r.Body, maxGeoJSONBytes)
+	var doc struct {
+		geoJSONFeature
+		Features []geoJSONFeature `json:"features"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid GeoJSON body"))
+		logger.Error(ctx, "failed to parse geojson import", "error", err)
+		return
+	}
+
+	var features []geoJSONFeature
+	switch doc.Type {
+	case "FeatureCollection":
+		features = doc.Features
+	case "Feature":
+		features = []geoJSONFeature{doc.geoJSONFeature}
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("type must be Feature or FeatureCollection"))
+		return
+	}
+	if len(features) == 0 {
+		helper.RespondError(w, r, apperror.BadRequest("No features to import"))
+		return
+	}
+
+	areas := make([]*store.ServiceArea, 0, len(features))
+	seen := make(map[string]bool, len(features))
+	for i := range features {
+		a, msg := parseFeature(&features[i])
+		if msg != "" {
+			helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("features[%d]: %s", i, msg)))
+			return
+		}
+		if seen[a.Name] {
+			helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("features[%d]: duplicate name %q", i, a.Name)))
+			return
+		}
+		seen[a.Name] = true
+		areas = append(areas, a)
+	}
+
+	saved, err := h.AreaStore.Import(ctxTimeout, areas)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to import areas")
+		return
+	}
+	out := make([]map[string]any, 0, len(saved))
+	for i := range saved {
+		out = append(out, areaFeature(&saved[i]))
+	}
+
+	logger.Audit(ctx, logger.AuditServiceAreaImport, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"features": len(out),
+	})
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{"type": "FeatureCollection", "features": out})
+}
+
+// HandleExport returns areas as a FeatureCollection suitable for editing and re-importing.
+func (h *AreaHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != store.AreaKindServiceArea && kind != store.AreaKindZone {
+		helper.RespondError(w, r, apperror.BadRequest("kind must be service_area or zone"))
+		return
+	}
+	list, err := h.AreaStore.List(ctxTimeout, kind)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to list areas")
+		return
+	}
+	features := make([]map[string]any, 0, len(list))
+	for i := range list {
+		features = append(features, areaFeature(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{"type": "FeatureCollection", "features": features})
+}
+
+func (h *AreaHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	areaID, err := helper.URLParamUUID(r, "areaID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid area id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	a, err := h.AreaStore.GetByID(ctxTimeout, areaID)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to load area")
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, areaFeature(a))
+}
+
+func (h *AreaHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	areaID, err := helper.URLParamUUID(r, "areaID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid area id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := h.AreaStore.Delete(ctxTimeout, areaID); err != nil {
+		respondAreaError(w, r, err, "Failed to delete area")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditServiceAreaDelete, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"area_id": areaID,
+	})
+	helper.RespondMessage(w, r, http.StatusOK, "Area deleted")
+}
+
+func (h *AreaHandler) HandleUpsertRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		FromZoneID   uuid.UUID `json:"from_zone_id"`
+		ToZoneID     uuid.UUID `json:"to_zone_id"`
+		VehicleClass string    `json:"vehicle_class"`
+		AmountCents  int       `json:"amount_cents"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse zone rate", "error", err)
+		return
+	}
+	if body.FromZoneID == uuid.Nil || body.ToZoneID == uuid.Nil {
+		helper.RespondError(w, r, apperror.BadRequest("from_zone_id and to_zone_id are required"))
+		return
+	}
+	if !store.ValidVehicleClass(body.VehicleClass) {
+		helper.RespondError(w, r, apperror.BadRequest("vehicle_class must be escalade, suburban or sprinter"))
+		return
+	}
+	if body.AmountCents <= 0 {
+		helper.RespondError(w, r, apperror.BadRequest("amount_cents must be positive"))
+		return
+	}
+
+	out, err := h.AreaStore.UpsertRate(ctxTimeout, &store.ZoneRate{
+		FromZoneID:   body.FromZoneID,
+		ToZoneID:     body.ToZoneID,
+		VehicleClass: body.VehicleClass,
+		AmountCents:  body.AmountCents,
+	})
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to save zone rate")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditZoneRate, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"rate_id":       out.ID,
+		"from_zone_id":  out.FromZoneID,
+		"to_zone_id":    out.ToZoneID,
+		"vehicle_class": out.VehicleClass,
+		"amount_cents":  out.AmountCents,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, zoneRateResponse(out))
+}
+
+func (h *AreaHandler) HandleListRates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	list, err := h.AreaStore.ListRates(ctxTimeout)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to list zone rates")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, zoneRateResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *AreaHandler) HandleDeleteRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	rateID, err := helper.URLParamUUID(r, "rateID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid rate id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := h.AreaStore.DeleteRate(ctxTimeout, rateID); err != nil {
+		respondAreaError(w, r, err, "Failed to delete zone rate")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditZoneRate, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"rate_id": rateID,
+		"action":  "delete",
+	})
+	helper.RespondMessage(w, r, http.StatusOK, "Zone rate deleted")
+}
+
+func queryPoint(r *http.Request, latKey, lngKey string) (geo.Point, bool) {
+	lat, err := strconv.ParseFloat(r.URL.Query().Get(latKey), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return geo.Point{}, false
+	}
+	lng, err := strconv.ParseFloat(r.URL.Query().Get(lngKey), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: lat, Lng: lng}, true
+}
+
+// resolveZones returns the active service areas containing p and, when there is at least one, the
+// pricing zones containing it. No areas means p is outside the service area.
+func (h *AreaHandler) resolveZones(ctx context.Context, p geo.Point) ([]store.ServiceArea, []store.ServiceArea, error) {
+	areas, err := h.AreaStore.Containing(ctx, p, store.AreaKindServiceArea)
+	if err != nil || len(areas) == 0 {
+		return nil, nil, err
+	}
+	zones, err := h.AreaStore.Containing(ctx, p, store.AreaKindZone)
+	if err != nil {
+		return nil, nil, err
+	}
+	return areas, zones, nil
+}
+
+func zoneIDs(zones []store.ServiceArea) []uuid.UUID {
+	ids := make([]uuid.UUID, len(zones))
+	for i := range zones {
+		ids[i] = zones[i].ID
+	}
+	return ids
+}
+
+func zoneNames(zones []store.ServiceArea) []string {
+	names := make([]string, len(zones))
+	for i := range zones {
+		names[i] = zones[i].Name
+	}
+	return names
+}
+
+// HandleCheck reports whether ?lat=&lng= is serviceable and which pricing zones contain it.
+func (h *AreaHandler) HandleCheck(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := queryPoint(r, "lat", "lng")
+	if !ok {
+		helper.RespondError(w, r, apperror.BadRequest("lat and lng must be valid coordinates"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	areas, zones, err := h.resolveZones(ctxTimeout, p)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to check service area")
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{
+		"in_service_area": len(areas) > 0,
+		"zones":           zoneNames(zones),
+	})
+}
+
+// HandleFlatRate is the zone step of pricing: it rejects trips with either end outside every service
+// area and returns the zone-to-zone flat rate if one applies, with the taxes of the jurisdictions at
+// either end itemized for the pickup date (?pickup_at, default now). A 404 means distance pricing applies.
+func (h *AreaHandler) HandleFlatRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pickup, ok := queryPoint(r, "pickup_lat", "pickup_lng")
+	if !ok {
+		helper.RespondError(w, r, apperror.BadRequest("pickup_lat and pickup_lng must be valid coordinates"))
+		return
+	}
+	dropoff, ok := queryPoint(r, "dropoff_lat", "dropoff_lng")
+	if !ok {
+		helper.RespondError(w, r, apperror.BadRequest("dropoff_lat and dropoff_lng must be valid coordinates"))
+		return
+	}
+	class := r.URL.Query().Get("class")
+	if !store.ValidVehicleClass(class) {
+		helper.RespondError(w, r, apperror.BadRequest("class must be escalade, suburban or sprinter"))
+		return
+	}
+	pickupAt := time.Now()
+	if v := r.URL.Query().Get("pickup_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest("pickup_at must be an RFC3339 timestamp"))
+			return
+		}
+		pickupAt = t
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pickupAreas, fromZones, err := h.resolveZones(ctxTimeout, pickup)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to resolve pickup zone")
+		return
+	}
+	dropoffAreas, toZones, err := h.resolveZones(ctxTimeout, dropoff)
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to resolve dropoff zone")
+		return
+	}
+	if len(pickupAreas) == 0 || len(dropoffAreas) == 0 {
+		helper.RespondError(w, r, apperror.New(apperror.CodeValidationError, "Trip is outside our service area", http.StatusUnprocessableEntity))
+		return
+	}
+
+	rate, err := h.AreaStore.FindRate(ctxTimeout, zoneIDs(fromZones), zoneIDs(toZones), class)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("No flat rate for this trip; distance pricing applies"))
+			return
+		}
+		respondAreaError(w, r, err, "Failed to look up zone rate")
+		return
+	}
+
+	// The date is taken as written in pickup_at, so a local evening pickup isn't taxed as the next UTC day.
+	y, m, d := pickupAt.Date()
+	rules, err := h.TaxStore.Applicable(ctxTimeout,
+		append(zoneIDs(pickupAreas), zoneIDs(fromZones)...),
+		append(zoneIDs(dropoffAreas), zoneIDs(toZones)...),
+		time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		respondAreaError(w, r, err, "Failed to look up taxes")
+		return
+	}
+	lines, taxCents := tax.Calculate(int64(rate.AmountCents), rules)
+
+	resp := zoneRateResponse(rate)
+	resp["taxes"] = taxLinesResponse(lines)
+	resp["tax_cents"] = taxCents
+	resp["total_cents"] = int64(rate.AmountCents) + taxCents
+	helper.RespondJSON(w, r, http.StatusOK, resp)
+}
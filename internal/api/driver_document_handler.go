@@ -0,0 +1,353 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/blob"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/secure"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDocumentBytes = 10 << 20
+	downloadURLTTL   = 5 * time.Minute
+)
+
+// allowedDocumentTypes maps sniffed MIME types to the extension used in storage keys.
+var allowedDocumentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+type DriverDocumentHandler struct {
+	DriverStore   store.DriverStore
+	DocumentStore store.DriverDocumentStore
+	Blobs         blob.BlobStore
+	URLSigner     *secure.URLSigner
+}
+
+func NewDriverDocumentHandler(ds store.DriverStore, dds store.DriverDocumentStore, bs blob.BlobStore, us *secure.URLSigner) *DriverDocumentHandler {
+	return &DriverDocumentHandler{ds, dds, bs, us}
+}
+
+func documentResponse(d *store.DriverDocument) map[string]any {
+	resp := map[string]any{
+		"id":           d.ID,
+		"driver_id":    d.DriverID,
+		"type":         d.DocType,
+		"file_name":    d.FileName,
+		"content_type": d.ContentType,
+		"size_bytes":   d.SizeBytes,
+		"sha256":       d.SHA256,
+		"uploaded_at":  d.UploadedAt,
+	}
+	if d.ExpiresOn.Valid {
+		resp["expires_on"] = d.ExpiresOn.Time.Format(time.DateOnly)
+		resp["expired"] = d.ExpiresOn.Time.Before(time.Now().Truncate(24 * time.Hour))
+	}
+	return resp
+}
+
+func (h *DriverDocumentHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Check the caller is a driver before reading up to 10 MB of multipart body.
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	if driver.Status == store.DriverStatusRejected {
+		helper.RespondError(w, r, apperror.Conflict("Resubmit your driver application before uploading documents"))
+		return
+	}
+
+	// Same MaxBytesReader guard as the JSON endpoints, sized for the file plus multipart overhead.
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentBytes+(1<<20))
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			helper.RespondError(w, r, apperror.New(apperror.CodeBadRequest, "File exceeds the 10 MB limit", http.StatusRequestEntityTooLarge))
+			return
+		}
+		helper.RespondError(w, r, apperror.BadRequest("Invalid multipart form"))
+		logger.Error(ctx, "failed to parse document upload", "error", err)
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	docType := strings.TrimSpace(r.FormValue("type"))
+	switch docType {
+	case store.DocumentTypeLicense, store.DocumentTypeInsurance, store.DocumentTypeChauffeurPermit:
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("type must be one of license, insurance, chauffeur_permit"))
+		return
+	}
+
+	expiresOn, err := time.Parse(time.DateOnly, strings.TrimSpace(r.FormValue("expires_on")))
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("expires_on must be a date in YYYY-MM-DD format"))
+		return
+	}
+	if expiresOn.Before(time.Now().Truncate(24 * time.Hour)) {
+		helper.RespondError(w, r, apperror.BadRequest("Document is already expired"))
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("file is required"))
+		return
+	}
+	defer file.Close()
+	if header.Size <= 0 || header.Size > maxDocumentBytes {
+		helper.RespondError(w, r, apperror.New(apperror.CodeBadRequest, "File exceeds the 10 MB limit", http.StatusRequestEntityTooLarge))
+		return
+	}
+
+	// Trust the bytes, not the client-declared Content-Type.
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		helper.RespondError(w, r, apperror.BadRequest("Unable to read file"))
+		return
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	ext, ok := allowedDocumentTypes[contentType]
+	if !ok {
+		helper.RespondError(w, r, apperror.New(apperror.CodeBadRequest, "File must be a JPEG, PNG or PDF", http.StatusUnsupportedMediaType))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to read file", err))
+		return
+	}
+
+	key := fmt.Sprintf("drivers/%s/%s/%s%s", driver.ID, docType, uuid.NewString(), ext)
+	hasher := sha256.New()
+	if err := h.Blobs.Put(ctxTimeout, key, io.TeeReader(file, hasher), header.Size, contentType); err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to store document", err))
+		logger.Error(ctx, "failed to store document", "driver_id", driver.ID, "error", err)
+		return
+	}
+
+	doc, err := h.DocumentStore.Create(ctxTimeout, &store.DriverDocument{
+		DriverID:    driver.ID,
+		DocType:     docType,
+		StorageKey:  key,
+		FileName:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		SizeBytes:   header.Size,
+		SHA256:      hex.EncodeToString(hasher.Sum(nil)),
+		ExpiresOn:   sql.NullTime{Time: expiresOn, Valid: true},
+		UploadedBy:  &userID,
+	})
+	if err != nil {
+		if delErr := h.Blobs.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+			logger.Warn(ctx, "failed to clean up orphaned blob", "key", key, "error", delErr)
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to save document", err))
+		logger.Error(ctx, "failed to save document record", "driver_id", driver.ID, "error", err)
+		return
+	}
+
+	logger.Info(ctx, "driver document uploaded", "driver_id", driver.ID, "document_id", doc.ID, "type", docType)
+	logger.Audit(ctx, logger.AuditDriverDocumentUpload, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id":   driver.ID,
+		"document_id": doc.ID,
+		"type":        docType,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, documentResponse(doc))
+}
+
+func (h *DriverDocumentHandler) HandleListMine(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	h.respondDocuments(ctxTimeout, w, r, driver.ID)
+}
+
+func (h *DriverDocumentHandler) HandleListForDriver(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	driverID, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	h.respondDocuments(ctxTimeout, w, r, driverID)
+}
+
+func (h *DriverDocumentHandler) respondDocuments(ctx context.Context, w http.ResponseWriter, r *http.Request, driverID uuid.UUID) {
+	docs, err := h.DocumentStore.ListByDriver(ctx, driverID)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to list documents", err))
+		logger.Error(r.Context(), "failed to list driver documents", "driver_id", driverID, "error", err)
+		return
+	}
+	out := make([]map[string]any, 0, len(docs))
+	for i := range docs {
+		out = append(out, documentResponse(&docs[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *DriverDocumentHandler) HandleListExpiring(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	days := helper.QueryInt(r, "within_days", 30, 0, 365)
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+
+	before := time.Now().UTC().AddDate(0, 0, days)
+	docs, err := h.DocumentStore.ListExpiring(ctxTimeout, before, limit, offset)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to list expiring documents", err))
+		logger.Error(ctx, "failed to list expiring documents", "error", err)
+		return
+	}
+	out := make([]map[string]any, 0, len(docs))
+	for i := range docs {
+		out = append(out, documentResponse(&docs[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+// HandleMyDownloadURL issues a signed link for one of the caller's own documents.
+func (h *DriverDocumentHandler) HandleMyDownloadURL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	h.respondDownloadURL(ctxTimeout, w, r, driver.ID)
+}
+
+func (h *DriverDocumentHandler) HandleDownloadURL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	driverID, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	h.respondDownloadURL(ctxTimeout, w, r, driverID)
+}
+
+func (h *DriverDocumentHandler) respondDownloadURL(ctx context.Context, w http.ResponseWriter, r *http.Request, driverID uuid.UUID) {
+	docID, err := helper.URLParamUUID(r, "documentID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid document id"))
+		return
+	}
+	doc, err := h.DocumentStore.GetByID(ctx, docID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		helper.RespondError(w, r, apperror.InternalError("Failed to load document", err))
+		logger.Error(r.Context(), "failed to load document", "document_id", docID, "error", err)
+		return
+	}
+	if doc == nil || doc.DriverID != driverID {
+		helper.RespondError(w, r, apperror.NotFound("Document not found"))
+		return
+	}
+
+	expires, sig := h.URLSigner.Sign(doc.ID.String(), downloadURLTTL, time.Now())
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", sig)
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{
+		"url":        fmt.Sprintf("/api/v1/documents/%s/download?%s", doc.ID, q.Encode()),
+		"expires_at": time.Unix(expires, 0).UTC(),
+	})
+}
+
+// HandleDownload serves a document to anyone holding a valid, unexpired signed link.
+func (h *DriverDocumentHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	docID, err := helper.URLParamUUID(r, "documentID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid document id"))
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		helper.RespondError(w, r, apperror.Forbidden("Invalid or expired link"))
+		return
+	}
+	if err := h.URLSigner.Verify(docID.String(), expires, r.URL.Query().Get("signature"), time.Now()); err != nil {
+		logger.Warn(ctx, "rejected document download link", "document_id", docID, "error", err)
+		helper.RespondError(w, r, apperror.Forbidden("Invalid or expired link"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	doc, err := h.DocumentStore.GetByID(ctxTimeout, docID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("Document not found"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to load document", err))
+		logger.Error(ctx, "failed to load document", "document_id", docID, "error", err)
+		return
+	}
+
+	body, err := h.Blobs.Get(ctxTimeout, doc.StorageKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("Document not found"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to read document", err))
+		logger.Error(ctx, "failed to read document blob", "document_id", docID, "error", err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(doc.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Warn(ctx, "document download interrupted", "document_id", docID, "error", err)
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+type DriverHandler struct {
+	DriverStore store.DriverStore
+}
+
+func NewDriverHandler(ds store.DriverStore) *DriverHandler {
+	return &DriverHandler{ds}
+}
+
+func driverResponse(d *store.Driver) map[string]any {
+	resp := map[string]any{
+		"id":                      d.ID,
+		"user_id":                 d.UserID,
+		"first_name":              d.FirstName,
+		"last_name":               d.LastName,
+		"phone":                   d.Phone,
+		"license_number":          d.LicenseNumber,
+		"license_state":           d.LicenseState,
+		"license_expiry":          d.LicenseExpiry.Format(time.DateOnly),
+		"home_base":               d.HomeBase,
+		"background_check_status": d.BackgroundCheckStatus,
+		"rating":                  d.Rating,
+		"status":                  d.Status,
+		"on_duty":                 d.OnDuty,
+		"submitted_at":            d.SubmittedAt,
+	}
+	if d.RejectionReason.Valid {
+		resp["rejection_reason"] = d.RejectionReason.String
+	}
+	if d.ReviewedAt.Valid {
+		resp["reviewed_at"] = d.ReviewedAt.Time
+		resp["reviewed_by"] = d.ReviewedBy
+	}
+	return resp
+}
+
+func (h *DriverHandler) HandleApply(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		helper.RespondError(w, r, apperror.Unauthorized("Authentication required"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		FirstName     string `json:"first_name"`
+		LastName      string `json:"last_name"`
+		Phone         string `json:"phone"`
+		LicenseNumber string `json:"license_number"`
+		LicenseState  string `json:"license_state"`
+		LicenseExpiry string `json:"license_expiry"`
+		HomeBase      string `json:"home_base"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse driver application", "error", err)
+		return
+	}
+
+	expiry, err := time.Parse(time.DateOnly, strings.TrimSpace(body.LicenseExpiry))
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("license_expiry must be a date in YYYY-MM-DD format"))
+		return
+	}
+	if expiry.Before(time.Now().Truncate(24 * time.Hour)) {
+		helper.RespondError(w, r, apperror.BadRequest("Driver license is expired"))
+		return
+	}
+
+	d := &store.Driver{
+		UserID:        userID,
+		FirstName:     body.FirstName,
+		LastName:      body.LastName,
+		Phone:         body.Phone,
+		LicenseNumber: body.LicenseNumber,
+		LicenseState:  body.LicenseState,
+		LicenseExpiry: expiry,
+		HomeBase:      body.HomeBase,
+	}
+	for _, f := range []struct{ name, value string }{
+		{"first_name", d.FirstName},
+		{"last_name", d.LastName},
+		{"phone", d.Phone},
+		{"license_number", d.LicenseNumber},
+		{"license_state", d.LicenseState},
+		{"home_base", d.HomeBase},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			helper.RespondError(w, r, apperror.BadRequest(f.name+" is required"))
+			return
+		}
+	}
+
+	out, err := h.DriverStore.Submit(ctxTimeout, d)
+	if err != nil {
+		logger.Audit(ctx, logger.AuditDriverApplicationSubmit, &userID, helper.ClientIP(r), r.UserAgent(), false, map[string]any{
+			"reason": err.Error(),
+		})
+		switch {
+		case errors.Is(err, store.ErrDriverApplicationExists):
+			helper.RespondError(w, r, apperror.Conflict("A driver application already exists for this account"))
+		case errors.Is(err, store.ErrDuplicateLicense):
+			helper.RespondError(w, r, apperror.Conflict("License number is already registered"))
+		case errors.Is(err, store.ErrStaffCannotDrive):
+			helper.RespondError(w, r, apperror.Forbidden("Admin accounts cannot apply as drivers"))
+		default:
+			helper.RespondError(w, r, apperror.InternalError("Failed to submit driver application", err))
+			logger.Error(ctx, "failed to submit driver application", "user_id", userID, "error", err)
+		}
+		return
+	}
+
+	logger.Info(ctx, "driver application submitted", "user_id", userID, "driver_id", out.ID)
+	logger.Audit(ctx, logger.AuditDriverApplicationSubmit, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id": out.ID,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, driverResponse(out))
+}
+
+func (h *DriverHandler) HandleGetMyApplication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		helper.RespondError(w, r, apperror.Unauthorized("Authentication required"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	d, err := h.DriverStore.GetByUserID(ctxTimeout, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("No driver application found"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to load driver application", err))
+		logger.Error(ctx, "failed to load driver application", "user_id", userID, "error", err)
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, driverResponse(d))
+}
+
+func (h *DriverHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.DriverStatusPending, store.DriverStatusApproved, store.DriverStatusRejected:
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("status must be one of pending, approved, rejected"))
+		return
+	}
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+
+	drivers, err := h.DriverStore.List(ctxTimeout, status, limit, offset)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to list drivers", err))
+		logger.Error(ctx, "failed to list drivers", "error", err)
+		return
+	}
+
+	out := make([]map[string]any, 0, len(drivers))
+	for i := range drivers {
+		out = append(out, driverResponse(&drivers[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *DriverHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	d, err := h.DriverStore.GetByID(ctxTimeout, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("Driver not found"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to load driver", err))
+		logger.Error(ctx, "failed to load driver", "driver_id", id, "error", err)
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, driverResponse(d))
+}
+
+func (h *DriverHandler) HandleSetBackgroundCheck(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse background check request", "error", err)
+		return
+	}
+	switch body.Status {
+	case store.BackgroundCheckNotStarted, store.BackgroundCheckPending, store.BackgroundCheckClear, store.BackgroundCheckFailed:
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("status must be one of not_started, pending, clear, failed"))
+		return
+	}
+
+	d, err := h.DriverStore.SetBackgroundCheck(ctxTimeout, id, body.Status)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("Driver not found"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to update background check", err))
+		logger.Error(ctx, "failed to update background check", "driver_id", id, "error", err)
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverBackgroundCheck, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id":      d.ID,
+		"target_user_id": d.UserID,
+		"status":         d.BackgroundCheckStatus,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, driverResponse(d))
+}
+
+func (h *DriverHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	d, err := h.DriverStore.Approve(ctxTimeout, id, adminID)
+	if err != nil {
+		logger.Audit(ctx, logger.AuditDriverApprove, &adminID, helper.ClientIP(r), r.UserAgent(), false, map[string]any{
+			"driver_id": id,
+			"reason":    err.Error(),
+		})
+		respondReviewError(w, r, err, id)
+		return
+	}
+
+	logger.Info(ctx, "driver approved", "driver_id", d.ID, "user_id", d.UserID, "admin_id", adminID)
+	logger.Audit(ctx, logger.AuditDriverApprove, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id":      d.ID,
+		"target_user_id": d.UserID,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, driverResponse(d))
+}
+
+func (h *DriverHandler) HandleReject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse driver rejection", "error", err)
+		return
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if reason == "" {
+		helper.RespondError(w, r, apperror.BadRequest("reason is required"))
+		return
+	}
+
+	d, err := h.DriverStore.Reject(ctxTimeout, id, adminID, reason)
+	if err != nil {
+		logger.Audit(ctx, logger.AuditDriverReject, &adminID, helper.ClientIP(r), r.UserAgent(), false, map[string]any{
+			"driver_id": id,
+			"reason":    err.Error(),
+		})
+		respondReviewError(w, r, err, id)
+		return
+	}
+
+	logger.Info(ctx, "driver rejected", "driver_id", d.ID, "user_id", d.UserID, "admin_id", adminID)
+	logger.Audit(ctx, logger.AuditDriverReject, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id":        d.ID,
+		"target_user_id":   d.UserID,
+		"rejection_reason": reason,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, driverResponse(d))
+}
+
+func respondReviewError(w http.ResponseWriter, r *http.Request, err error, id uuid.UUID) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Driver not found"))
+	case errors.Is(err, store.ErrDriverNotPending):
+		helper.RespondError(w, r, apperror.Conflict("Driver application is not pending review"))
+	case errors.Is(err, store.ErrBackgroundCheckNotClear):
+		helper.RespondError(w, r, apperror.Conflict("Background check must be clear before approval"))
+	case errors.Is(err, store.ErrStaffCannotDrive):
+		helper.RespondError(w, r, apperror.Conflict("Applicant is an admin and cannot be made a driver"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError("Failed to review driver application", err))
+		logger.Error(r.Context(), "failed to review driver application", "driver_id", id, "error", err)
+	}
+}
+
+// currentDriver loads the caller's driver record, answering the request itself on failure.
+func currentDriver(ctx context.Context, w http.ResponseWriter, r *http.Request, ds store.DriverStore) (*store.Driver, bool) {
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		helper.RespondError(w, r, apperror.Unauthorized("Authentication required"))
+		return nil, false
+	}
+	d, err := ds.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("No driver profile found"))
+			return nil, false
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to load driver profile", err))
+		logger.Error(ctx, "failed to load driver profile", "user_id", userID, "error", err)
+		return nil, false
+	}
+	return d, true
+}
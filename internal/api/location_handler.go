@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/tracking"
+)
+
+const (
+	maxPingsPerBatch = 200
+	// Devices buffer while offline, so accept fixes up to a day old but only slightly in the future.
+	maxPingAge       = 24 * time.Hour
+	maxPingClockSkew = time.Minute
+	maxSpeedMPS      = 90 // ~200 mph; anything faster is a bad fix
+)
+
+type LocationHandler struct {
+	DriverStore   store.DriverStore
+	LocationStore store.LocationStore
+	Index         *tracking.Index
+}
+
+func NewLocationHandler(ds store.DriverStore, ls store.LocationStore, idx *tracking.Index) *LocationHandler {
+	return &LocationHandler{ds, ls, idx}
+}
+
+func locationResponse(p *store.LocationPing) map[string]any {
+	return map[string]any{
+		"driver_id":   p.DriverID,
+		"recorded_at": p.RecordedAt,
+		"lat":         p.Lat,
+		"lng":         p.Lng,
+		"heading":     p.Heading,
+		"speed_mps":   p.SpeedMPS,
+		"accuracy_m":  p.AccuracyM,
+	}
+}
+
+type pingInput struct {
+	Lat       *float64 `json:"lat"`
+	Lng       *float64 `json:"lng"`
+	Heading   *float32 `json:"heading"`
+	Speed     *float32 `json:"speed"`
+	Accuracy  *float32 `json:"accuracy"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// validate returns the first problem with the ping, or "" if it is usable.
+func (in *pingInput) validate(now time.Time) (time.Time, string) {
+	if in.Lat == nil || in.Lng == nil {
+		return time.Time{}, "lat and lng are required"
+	}
+	if math.IsNaN(*in.Lat) || *in.Lat < -90 || *in.Lat > 90 {
+		return time.Time{}, "lat must be between -90 and 90"
+	}
+	if math.IsNaN(*in.Lng) || *in.Lng < -180 || *in.Lng > 180 {
+		return time.Time{}, "lng must be between -180 and 180"
+	}
+	if in.Heading != nil && (*in.Heading < 0 || *in.Heading >= 360) {
+		return time.Time{}, "heading must be in [0, 360)"
+	}
+	if in.Speed != nil && (*in.Speed < 0 || *in.Speed > maxSpeedMPS) {
+		return time.Time{}, fmt.Sprintf("speed must be between 0 and %d m/s", maxSpeedMPS)
+	}
+	if in.Accuracy != nil && *in.Accuracy < 0 {
+		return time.Time{}, "accuracy must not be negative"
+	}
+	ts, err := time.Parse(time.RFC3339Nano, in.Timestamp)
+	if err != nil {
+		return time.Time{}, "timestamp must be an RFC3339 timestamp"
+	}
+	if ts.After(now.Add(maxPingClockSkew)) {
+		return time.Time{}, "timestamp is in the future"
+	}
+	if now.Sub(ts) > maxPingAge {
+		return time.Time{}, "timestamp is too old"
+	}
+	return ts, ""
+}
+
+// HandleIngest accepts a batch of pings from the driver's device. Invalid pings are reported back
+// individually rather than failing the batch; valid ones are stored in timestamp order and the
+// latest fix updates the in-memory index only if it is newer than what is already held.
+func (h *LocationHandler) HandleIngest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Pings []pingInput `json:"pings"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse location batch", "error", err)
+		return
+	}
+	if len(body.Pings) == 0 {
+		helper.RespondError(w, r, apperror.BadRequest("pings must not be empty"))
+		return
+	}
+	if len(body.Pings) > maxPingsPerBatch {
+		helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("At most %d pings per batch", maxPingsPerBatch)))
+		return
+	}
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	// Positions are only collected while the driver is working.
+	if !driver.OnDuty {
+		helper.RespondError(w, r, apperror.Conflict("Go on duty before sending locations"))
+		return
+	}
+
+	now := time.Now()
+	pings := make([]store.LocationPing, 0, len(body.Pings))
+	rejected := make([]map[string]any, 0)
+	for i := range body.Pings {
+		in := &body.Pings[i]
+		ts, msg := in.validate(now)
+		if msg != "" {
+			rejected = append(rejected, map[string]any{"index": i, "error": msg})
+			continue
+		}
+		pings = append(pings, store.LocationPing{
+			DriverID:   driver.ID,
+			RecordedAt: ts.UTC(),
+			Lat:        *in.Lat,
+			Lng:        *in.Lng,
+			Heading:    in.Heading,
+			SpeedMPS:   in.Speed,
+			AccuracyM:  in.Accuracy,
+			ReceivedAt: now,
+		})
+	}
+	sort.Slice(pings, func(i, j int) bool { return pings[i].RecordedAt.Before(pings[j].RecordedAt) })
+
+	inserted, err := h.LocationStore.InsertBatch(ctxTimeout, pings)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to store locations", err))
+		logger.Error(ctx, "failed to store locations", "driver_id", driver.ID, "error", err)
+		return
+	}
+
+	updated := false
+	if len(pings) > 0 {
+		updated = h.Index.Update(pings[len(pings)-1])
+	}
+
+	helper.RespondJSON(w, r, http.StatusAccepted, map[string]any{
+		"accepted":       len(pings),
+		"stored":         inserted,
+		"duplicates":     int64(len(pings)) - inserted,
+		"rejected":       rejected,
+		"latest_updated": updated,
+	})
+}
+
+// HandleLatest returns a driver's current position from the in-memory index, falling back to the
+// most recent stored breadcrumb after a restart.
+func (h *LocationHandler) HandleLatest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	driverID, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	if p, ok := h.Index.Get(driverID, time.Now()); ok {
+		helper.RespondJSON(w, r, http.StatusOK, locationResponse(&p))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	p, err := h.LocationStore.Latest(ctxTimeout, driverID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			helper.RespondError(w, r, apperror.NotFound("No location recorded for this driver"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to load location", err))
+		logger.Error(ctx, "failed to load location", "driver_id", driverID, "error", err)
+		return
+	}
+	h.Index.Update(*p)
+	helper.RespondJSON(w, r, http.StatusOK, locationResponse(p))
+}
+
+// HandleBreadcrumbs returns the stored trail for ?starts_at..?ends_at (RFC3339, at most 24 hours apart).
+func (h *LocationHandler) HandleBreadcrumbs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	driverID, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+	q := r.URL.Query()
+	from, to, msg := parseWindow(q.Get("starts_at"), q.Get("ends_at"), 24*time.Hour)
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 5000, 1, 20000)
+	list, err := h.LocationStore.Breadcrumbs(ctxTimeout, driverID, from, to, limit)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to load breadcrumbs", err))
+		logger.Error(ctx, "failed to load breadcrumbs", "driver_id", driverID, "error", err)
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, locationResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
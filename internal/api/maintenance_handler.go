@@ -0,0 +1,468 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+type MaintenanceHandler struct {
+	MaintenanceStore store.MaintenanceStore
+	VehicleStore     store.VehicleStore
+}
+
+func NewMaintenanceHandler(ms store.MaintenanceStore, vs store.VehicleStore) *MaintenanceHandler {
+	return &MaintenanceHandler{ms, vs}
+}
+
+func scheduleResponse(sch *store.MaintenanceSchedule) map[string]any {
+	resp := map[string]any{
+		"id":                   sch.ID,
+		"vehicle_id":           sch.VehicleID,
+		"kind":                 sch.Kind,
+		"interval_days":        sch.IntervalDays,
+		"interval_miles":       sch.IntervalMiles,
+		"last_performed_on":    sch.LastPerformedOn.Format(time.DateOnly),
+		"last_performed_miles": sch.LastPerformedMiles,
+		"next_due_miles":       sch.NextDueMiles,
+		"due_state":            sch.DueState,
+	}
+	if sch.NextDueOn.Valid {
+		resp["next_due_on"] = sch.NextDueOn.Time.Format(time.DateOnly)
+	}
+	if sch.CheckedAt.Valid {
+		resp["checked_at"] = sch.CheckedAt.Time
+	}
+	return resp
+}
+
+func recordResponse(rec *store.MaintenanceRecord) map[string]any {
+	resp := map[string]any{
+		"id":             rec.ID,
+		"vehicle_id":     rec.VehicleID,
+		"schedule_id":    rec.ScheduleID,
+		"kind":           rec.Kind,
+		"status":         rec.Status,
+		"scheduled_for":  rec.ScheduledFor.Format(time.DateOnly),
+		"odometer_miles": rec.OdometerMiles,
+		"cost_cents":     rec.CostCents,
+		"created_at":     rec.CreatedAt,
+	}
+	if rec.Description.Valid {
+		resp["description"] = rec.Description.String
+	}
+	if rec.Vendor.Valid {
+		resp["vendor"] = rec.Vendor.String
+	}
+	if rec.Notes.Valid {
+		resp["notes"] = rec.Notes.String
+	}
+	if rec.StartedAt.Valid {
+		resp["started_at"] = rec.StartedAt.Time
+	}
+	if rec.CompletedAt.Valid {
+		resp["completed_at"] = rec.CompletedAt.Time
+	}
+	return resp
+}
+
+func respondMaintenanceError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Vehicle or maintenance item not found"))
+	case errors.Is(err, store.ErrDuplicateSchedule):
+		helper.RespondError(w, r, apperror.Conflict("Vehicle already has a schedule of this kind"))
+	case errors.Is(err, store.ErrMaintenanceInvalidState):
+		helper.RespondError(w, r, apperror.Conflict("Maintenance record cannot transition from its current status"))
+	case errors.Is(err, store.ErrOdometerRollback):
+		helper.RespondError(w, r, apperror.Conflict("Odometer reading is lower than the recorded value"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+func (h *MaintenanceHandler) HandleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Kind               string `json:"kind"`
+		IntervalDays       *int32 `json:"interval_days"`
+		IntervalMiles      *int32 `json:"interval_miles"`
+		LastPerformedOn    string `json:"last_performed_on"`
+		LastPerformedMiles *int   `json:"last_performed_miles"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse maintenance schedule", "error", err)
+		return
+	}
+	if strings.TrimSpace(body.Kind) == "" {
+		helper.RespondError(w, r, apperror.BadRequest("kind is required"))
+		return
+	}
+	if body.IntervalDays == nil && body.IntervalMiles == nil {
+		helper.RespondError(w, r, apperror.BadRequest("interval_days or interval_miles is required"))
+		return
+	}
+	if (body.IntervalDays != nil && *body.IntervalDays <= 0) || (body.IntervalMiles != nil && *body.IntervalMiles <= 0) {
+		helper.RespondError(w, r, apperror.BadRequest("intervals must be positive"))
+		return
+	}
+
+	vehicle, err := h.VehicleStore.GetByID(ctxTimeout, vehicleID)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to load vehicle")
+		return
+	}
+
+	// Default to "performed today at the current odometer" so a new schedule starts its first interval now.
+	sch := &store.MaintenanceSchedule{
+		VehicleID:          vehicleID,
+		Kind:               body.Kind,
+		IntervalDays:       body.IntervalDays,
+		IntervalMiles:      body.IntervalMiles,
+		LastPerformedOn:    time.Now().UTC().Truncate(24 * time.Hour),
+		LastPerformedMiles: vehicle.OdometerMiles,
+	}
+	if body.LastPerformedOn != "" {
+		t, err := time.Parse(time.DateOnly, body.LastPerformedOn)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest("last_performed_on must be a date in YYYY-MM-DD format"))
+			return
+		}
+		sch.LastPerformedOn = t
+	}
+	if body.LastPerformedMiles != nil {
+		if *body.LastPerformedMiles < 0 {
+			helper.RespondError(w, r, apperror.BadRequest("last_performed_miles must not be negative"))
+			return
+		}
+		sch.LastPerformedMiles = *body.LastPerformedMiles
+	}
+
+	out, err := h.MaintenanceStore.CreateSchedule(ctxTimeout, sch)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to create maintenance schedule")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditMaintenanceSchedule, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id":  vehicleID,
+		"schedule_id": out.ID,
+		"kind":        out.Kind,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, scheduleResponse(out))
+}
+
+func (h *MaintenanceHandler) HandleListSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	list, err := h.MaintenanceStore.ListSchedules(ctxTimeout, vehicleID)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to list maintenance schedules")
+		return
+	}
+	respondSchedules(w, r, list)
+}
+
+func (h *MaintenanceHandler) HandleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+	scheduleID, err := helper.URLParamUUID(r, "scheduleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid schedule id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := h.MaintenanceStore.DeleteSchedule(ctxTimeout, vehicleID, scheduleID); err != nil {
+		respondMaintenanceError(w, r, err, "Failed to delete maintenance schedule")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditMaintenanceSchedule, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id":  vehicleID,
+		"schedule_id": scheduleID,
+		"action":      "delete",
+	})
+	helper.RespondMessage(w, r, http.StatusOK, "Maintenance schedule deleted")
+}
+
+// HandleListDue returns fleet-wide schedules flagged by the last maintenance check.
+func (h *MaintenanceHandler) HandleListDue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.MaintenanceStore.ListDue(ctxTimeout, limit, offset)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to list due maintenance")
+		return
+	}
+	respondSchedules(w, r, list)
+}
+
+func respondSchedules(w http.ResponseWriter, r *http.Request, list []store.MaintenanceSchedule) {
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, scheduleResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *MaintenanceHandler) HandleCreateRecord(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		ScheduleID   *uuid.UUID `json:"schedule_id"`
+		Kind         string     `json:"kind"`
+		Description  string     `json:"description"`
+		Vendor       string     `json:"vendor"`
+		ScheduledFor string     `json:"scheduled_for"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse maintenance record", "error", err)
+		return
+	}
+	if strings.TrimSpace(body.Kind) == "" {
+		helper.RespondError(w, r, apperror.BadRequest("kind is required"))
+		return
+	}
+	scheduledFor, err := time.Parse(time.DateOnly, body.ScheduledFor)
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("scheduled_for must be a date in YYYY-MM-DD format"))
+		return
+	}
+
+	out, err := h.MaintenanceStore.CreateRecord(ctxTimeout, &store.MaintenanceRecord{
+		VehicleID:    vehicleID,
+		ScheduleID:   body.ScheduleID,
+		Kind:         body.Kind,
+		Description:  store.ToNullString(strings.TrimSpace(body.Description)),
+		Vendor:       store.ToNullString(strings.TrimSpace(body.Vendor)),
+		ScheduledFor: scheduledFor,
+		CreatedBy:    &adminID,
+	})
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to create maintenance record")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditMaintenanceRecord, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": vehicleID,
+		"record_id":  out.ID,
+		"status":     out.Status,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, recordResponse(out))
+}
+
+func (h *MaintenanceHandler) HandleListRecords(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.MaintenanceStore.ListRecords(ctxTimeout, vehicleID, limit, offset)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to list maintenance records")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, recordResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+// recordIDs parses the vehicle and record path parameters shared by the record transitions.
+func recordIDs(w http.ResponseWriter, r *http.Request) (vehicleID, recordID uuid.UUID, ok bool) {
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return uuid.Nil, uuid.Nil, false
+	}
+	recordID, err = helper.URLParamUUID(r, "recordID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid record id"))
+		return uuid.Nil, uuid.Nil, false
+	}
+	return vehicleID, recordID, true
+}
+
+func (h *MaintenanceHandler) HandleStartRecord(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, recordID, ok := recordIDs(w, r)
+	if !ok {
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rec, err := h.MaintenanceStore.StartRecord(ctxTimeout, vehicleID, recordID, adminID)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to start maintenance")
+		return
+	}
+
+	logger.Info(ctx, "vehicle maintenance started", "vehicle_id", vehicleID, "record_id", recordID)
+	logger.Audit(ctx, logger.AuditMaintenanceRecord, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": vehicleID,
+		"record_id":  recordID,
+		"status":     rec.Status,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, recordResponse(rec))
+}
+
+func (h *MaintenanceHandler) HandleCompleteRecord(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, recordID, ok := recordIDs(w, r)
+	if !ok {
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		OdometerMiles *int   `json:"odometer_miles"`
+		CostCents     *int64 `json:"cost_cents"`
+		Notes         string `json:"notes"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse maintenance completion", "error", err)
+		return
+	}
+	if (body.OdometerMiles != nil && *body.OdometerMiles < 0) || (body.CostCents != nil && *body.CostCents < 0) {
+		helper.RespondError(w, r, apperror.BadRequest("odometer_miles and cost_cents must not be negative"))
+		return
+	}
+
+	rec, err := h.MaintenanceStore.CompleteRecord(ctxTimeout, vehicleID, recordID, body.OdometerMiles, body.CostCents, strings.TrimSpace(body.Notes))
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to complete maintenance")
+		return
+	}
+
+	logger.Info(ctx, "vehicle maintenance completed", "vehicle_id", vehicleID, "record_id", recordID)
+	logger.Audit(ctx, logger.AuditMaintenanceRecord, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": vehicleID,
+		"record_id":  recordID,
+		"status":     rec.Status,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, recordResponse(rec))
+}
+
+func (h *MaintenanceHandler) HandleCancelRecord(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, recordID, ok := recordIDs(w, r)
+	if !ok {
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rec, err := h.MaintenanceStore.CancelRecord(ctxTimeout, vehicleID, recordID)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to cancel maintenance")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditMaintenanceRecord, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": vehicleID,
+		"record_id":  recordID,
+		"status":     rec.Status,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, recordResponse(rec))
+}
+
+func (h *MaintenanceHandler) HandleUpdateOdometer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		OdometerMiles *int `json:"odometer_miles"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil || body.OdometerMiles == nil || *body.OdometerMiles < 0 {
+		helper.RespondError(w, r, apperror.BadRequest("odometer_miles is required and must not be negative"))
+		return
+	}
+
+	v, err := h.VehicleStore.UpdateOdometer(ctxTimeout, vehicleID, *body.OdometerMiles)
+	if err != nil {
+		respondMaintenanceError(w, r, err, "Failed to update odometer")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditVehicleUpdate, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id":     vehicleID,
+		"odometer_miles": v.OdometerMiles,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, vehicleResponse(v))
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+type PaymentHandler struct {
+	PaymentStore store.PaymentStore
+	UserStore    store.UserStore
+	Gateway      payments.PaymentGateway
+	// Provider names the configured gateway ("fake", "stripe") and is stored with every saved card.
+	Provider string
+}
+
+func NewPaymentHandler(ps store.PaymentStore, us store.UserStore, gw payments.PaymentGateway, provider string) *PaymentHandler {
+	return &PaymentHandler{ps, us, gw, provider}
+}
+
+func paymentMethodResponse(m *store.PaymentMethod) map[string]any {
+	return map[string]any{
+		"id":         m.ID,
+		"brand":      m.Brand,
+		"last4":      m.Last4,
+		"exp_month":  m.ExpMonth,
+		"exp_year":   m.ExpYear,
+		"is_default": m.IsDefault,
+		"created_at": m.CreatedAt,
+	}
+}
+
+func paymentIntentResponse(pi *store.PaymentIntent) map[string]any {
+	resp := map[string]any{
+		"id":                      pi.ID,
+		"booking_id":              pi.BookingID,
+		"payment_method_id":       pi.PaymentMethodID,
+		"status":                  pi.Status,
+		"currency":                pi.Currency,
+		"amount_authorized_cents": pi.AmountAuthorizedCents,
+		"amount_captured_cents":   pi.AmountCapturedCents,
+		"amount_refunded_cents":   pi.AmountRefundedCents,
+		"created_at":              pi.CreatedAt,
+		"updated_at":              pi.UpdatedAt,
+	}
+	if pi.FailureReason.Valid {
+		resp["failure_reason"] = pi.FailureReason.String
+	}
+	if pi.DisputedAt.Valid {
+		resp["disputed_at"] = pi.DisputedAt.Time
+	}
+	return resp
+}
+
+func respondPaymentError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Payment method not found"))
+	case errors.Is(err, store.ErrDuplicatePaymentMethod):
+		helper.RespondError(w, r, apperror.Conflict("Card is already saved"))
+	case errors.Is(err, payments.ErrInvalidRequest):
+		helper.RespondError(w, r, apperror.BadRequest("Card token was rejected by the payment provider"))
+	case errors.Is(err, payments.ErrCardDeclined):
+		helper.RespondError(w, r, apperror.New(apperror.CodeBadRequest, "Card was declined", http.StatusPaymentRequired))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+// HandleSaveMethod attaches a client-side tokenized card to the caller's provider customer,
+// creating the customer on first use.
+func (h *PaymentHandler) HandleSaveMethod(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.GetUserID(ctx)
+	if !ok {
+		helper.RespondError(w, r, apperror.Unauthorized("Authentication required"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse payment method", "error", err)
+		return
+	}
+	if strings.TrimSpace(body.Token) == "" {
+		helper.RespondError(w, r, apperror.BadRequest("token is required"))
+		return
+	}
+
+	customerID, err := h.PaymentStore.CustomerID(ctxTimeout, userID, h.Provider)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondPaymentError(w, r, err, "Failed to load payment customer")
+		return
+	}
+	user, err := h.UserStore.GetByID(ctxTimeout, userID)
+	if err != nil {
+		respondPaymentError(w, r, err, "Failed to load user")
+		return
+	}
+
+	card, err := h.Gateway.SaveCard(ctxTimeout, payments.SaveCardRequest{
+		CustomerID: customerID,
+		Email:      user.Email,
+		Token:      strings.TrimSpace(body.Token),
+	})
+	if err != nil {
+		respondPaymentError(w, r, err, "Failed to save card with payment provider")
+		return
+	}
+
+	m, err := h.PaymentStore.SaveMethod(ctxTimeout, &store.PaymentMethod{
+		UserID:             userID,
+		Provider:           h.Provider,
+		ProviderCustomerID: card.CustomerID,
+		ProviderMethodID:   card.MethodID,
+		Brand:              card.Brand,
+		Last4:              card.Last4,
+		ExpMonth:           int16(card.ExpMonth),
+		ExpYear:            int16(card.ExpYear),
+	})
+	if err != nil {
+		respondPaymentError(w, r, err, "Failed to save payment method")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditPaymentMethodAdd, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"payment_method_id": m.ID,
+		"brand":             m.Brand,
+		"last4":             m.Last4,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, paymentMethodResponse(m))
+}
+
+func (h *PaymentHandler) HandleListMethods(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	list, err := h.PaymentStore.ListMethods(ctxTimeout, userID)
+	if err != nil {
+		respondPaymentError(w, r, err, "Failed to list payment methods")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, paymentMethodResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *PaymentHandler) HandleSetDefaultMethod(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+	methodID, err := helper.URLParamUUID(r, "methodID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid payment method id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	m, err := h.PaymentStore.SetDefaultMethod(ctxTimeout, userID, methodID)
+	if err != nil {
+		respondPaymentError(w, r, err, "Failed to set default payment method")
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, paymentMethodResponse(m))
+}
+
+func (h *PaymentHandler) HandleRemoveMethod(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+	methodID, err := helper.URLParamUUID(r, "methodID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid payment method id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := h.PaymentStore.RemoveMethod(ctxTimeout, userID, methodID); err != nil {
+		respondPaymentError(w, r, err, "Failed to remove payment method")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditPaymentMethodRemove, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"payment_method_id": methodID,
+	})
+	helper.RespondMessage(w, r, http.StatusOK, "Payment method removed")
+}
+
+func (h *PaymentHandler) HandleListIntents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.PaymentStore.ListIntentsByUser(ctxTimeout, userID, limit, offset)
+	if err != nil {
+		respondPaymentError(w, r, err, "Failed to list payments")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, paymentIntentResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
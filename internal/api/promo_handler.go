@@ -0,0 +1,457 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/geo"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/promo"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+type latLng struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type PromoHandler struct {
+	PromoStore  store.PromoStore
+	CreditStore store.CreditStore
+	AreaStore   store.AreaStore
+}
+
+func NewPromoHandler(ps store.PromoStore, cs store.CreditStore, as store.AreaStore) *PromoHandler {
+	return &PromoHandler{ps, cs, as}
+}
+
+func promoResponse(p *store.PromoCode) map[string]any {
+	resp := map[string]any{
+		"id":                 p.ID,
+		"code":               p.Code,
+		"kind":               p.Kind,
+		"min_fare_cents":     p.MinFareCents,
+		"vehicle_classes":    p.VehicleClasses,
+		"zone_ids":           p.ZoneIDs,
+		"first_ride_only":    p.FirstRideOnly,
+		"starts_at":          p.StartsAt,
+		"ends_at":            p.EndsAt,
+		"max_redemptions":    p.MaxRedemptions,
+		"max_per_user":       p.MaxPerUser,
+		"redemption_count":   p.RedemptionCount,
+		"max_discount_cents": p.MaxDiscountCents,
+		"active":             p.Active,
+		"created_at":         p.CreatedAt,
+		"updated_at":         p.UpdatedAt,
+	}
+	if p.Kind == store.PromoKindPercentage {
+		resp["percent_bps"] = p.PercentBps
+	} else {
+		resp["amount_cents"] = p.AmountCents
+	}
+	if p.Description.Valid {
+		resp["description"] = p.Description.String
+	}
+	return resp
+}
+
+func creditEntryResponse(e *store.CreditEntry) map[string]any {
+	resp := map[string]any{
+		"id":           e.ID,
+		"kind":         e.Kind,
+		"amount_cents": e.AmountCents,
+		"reference_id": e.ReferenceID,
+		"created_at":   e.CreatedAt,
+	}
+	if e.Note.Valid {
+		resp["note"] = e.Note.String
+	}
+	return resp
+}
+
+func respondPromoError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Promo code or user not found"))
+	case errors.Is(err, store.ErrDuplicatePromoCode):
+		helper.RespondError(w, r, apperror.Conflict("Promo code already exists"))
+	case errors.Is(err, store.ErrInsufficientCredit):
+		helper.RespondError(w, r, apperror.New(apperror.CodeValidationError, "Credit balance can't go below zero", http.StatusUnprocessableEntity))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+func (h *PromoHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Code             string      `json:"code"`
+		Description      string      `json:"description"`
+		Kind             string      `json:"kind"`
+		PercentBps       int         `json:"percent_bps"`
+		AmountCents      int         `json:"amount_cents"`
+		MaxDiscountCents *int        `json:"max_discount_cents"`
+		MinFareCents     int         `json:"min_fare_cents"`
+		VehicleClasses   []string    `json:"vehicle_classes"`
+		ZoneIDs          []uuid.UUID `json:"zone_ids"`
+		FirstRideOnly    bool        `json:"first_ride_only"`
+		StartsAt         *time.Time  `json:"starts_at"`
+		EndsAt           *time.Time  `json:"ends_at"`
+		MaxRedemptions   *int        `json:"max_redemptions"`
+		MaxPerUser       *int        `json:"max_per_user"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse promo code", "error", err)
+		return
+	}
+
+	p := &store.PromoCode{
+		Code:        strings.ToUpper(strings.TrimSpace(body.Code)),
+		Description: sql.NullString{String: strings.TrimSpace(body.Description), Valid: strings.TrimSpace(body.Description) != ""},
+		Rules: promo.Rules{
+			Kind:             body.Kind,
+			MaxDiscountCents: body.MaxDiscountCents,
+			MinFareCents:     body.MinFareCents,
+			FirstRideOnly:    body.FirstRideOnly,
+		},
+		StartsAt:       time.Now(),
+		EndsAt:         body.EndsAt,
+		MaxRedemptions: body.MaxRedemptions,
+		MaxPerUser:     1,
+		CreatedBy:      &adminID,
+	}
+	if !promoCodePattern.MatchString(p.Code) {
+		helper.RespondError(w, r, apperror.BadRequest("code must be 3-50 letters, digits, dashes or underscores"))
+		return
+	}
+	switch p.Kind {
+	case store.PromoKindPercentage:
+		if body.PercentBps <= 0 || body.PercentBps > 10000 {
+			helper.RespondError(w, r, apperror.BadRequest("percent_bps must be between 1 and 10000"))
+			return
+		}
+		p.PercentBps = body.PercentBps
+	case store.PromoKindFixed:
+		if body.AmountCents <= 0 {
+			helper.RespondError(w, r, apperror.BadRequest("amount_cents must be positive"))
+			return
+		}
+		p.AmountCents = body.AmountCents
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("kind must be percentage or fixed"))
+		return
+	}
+	if (p.MaxDiscountCents != nil && *p.MaxDiscountCents <= 0) || p.MinFareCents < 0 {
+		helper.RespondError(w, r, apperror.BadRequest("max_discount_cents must be positive and min_fare_cents non-negative"))
+		return
+	}
+	if len(body.VehicleClasses) > 0 {
+		for _, c := range body.VehicleClasses {
+			if !store.ValidVehicleClass(c) {
+				helper.RespondError(w, r, apperror.BadRequest("vehicle_classes may only contain escalade, suburban or sprinter"))
+				return
+			}
+		}
+		p.VehicleClasses = body.VehicleClasses
+	}
+	if len(body.ZoneIDs) > 0 {
+		p.ZoneIDs = body.ZoneIDs
+	}
+	if body.StartsAt != nil {
+		p.StartsAt = *body.StartsAt
+	}
+	if p.EndsAt != nil && !p.EndsAt.After(p.StartsAt) {
+		helper.RespondError(w, r, apperror.BadRequest("ends_at must be after starts_at"))
+		return
+	}
+	if p.MaxRedemptions != nil && *p.MaxRedemptions <= 0 {
+		helper.RespondError(w, r, apperror.BadRequest("max_redemptions must be positive"))
+		return
+	}
+	if body.MaxPerUser != nil {
+		if *body.MaxPerUser <= 0 {
+			helper.RespondError(w, r, apperror.BadRequest("max_per_user must be positive"))
+			return
+		}
+		p.MaxPerUser = *body.MaxPerUser
+	}
+
+	out, err := h.PromoStore.Create(ctxTimeout, p)
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to create promo code")
+		return
+	}
+	logger.Audit(ctx, logger.AuditPromoCode, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"promo_id": out.ID,
+		"code":     out.Code,
+		"action":   "create",
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, promoResponse(out))
+}
+
+func (h *PromoHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.PromoStore.List(ctxTimeout, limit, offset)
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to list promo codes")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, promoResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *PromoHandler) HandleSetActive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	promoID, err := helper.URLParamUUID(r, "promoID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid promo id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Active *bool `json:"active"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil || body.Active == nil {
+		helper.RespondError(w, r, apperror.BadRequest("active is required"))
+		return
+	}
+
+	out, err := h.PromoStore.SetActive(ctxTimeout, promoID, *body.Active)
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to update promo code")
+		return
+	}
+	action := "deactivate"
+	if out.Active {
+		action = "activate"
+	}
+	logger.Audit(ctx, logger.AuditPromoCode, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"promo_id": out.ID,
+		"code":     out.Code,
+		"action":   action,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, promoResponse(out))
+}
+
+// HandleValidate previews what a code would take off a fare for the caller without redeeming it.
+// Redemption itself happens when a booking is made, through PromoStore.Redeem.
+func (h *PromoHandler) HandleValidate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Code         string  `json:"code"`
+		FareCents    int64   `json:"fare_cents"`
+		VehicleClass string  `json:"vehicle_class"`
+		Pickup       *latLng `json:"pickup"`
+		Dropoff      *latLng `json:"dropoff"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		return
+	}
+	if body.FareCents <= 0 {
+		helper.RespondError(w, r, apperror.BadRequest("fare_cents must be positive"))
+		return
+	}
+	if !store.ValidVehicleClass(body.VehicleClass) {
+		helper.RespondError(w, r, apperror.BadRequest("vehicle_class must be escalade, suburban or sprinter"))
+		return
+	}
+
+	p, err := h.PromoStore.GetByCode(ctxTimeout, strings.ToUpper(strings.TrimSpace(body.Code)))
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to load promo code")
+		return
+	}
+
+	trip := promo.Trip{FareCents: body.FareCents, VehicleClass: body.VehicleClass}
+	for _, pt := range []*latLng{body.Pickup, body.Dropoff} {
+		if pt == nil {
+			continue
+		}
+		if pt.Lat < -90 || pt.Lat > 90 || pt.Lng < -180 || pt.Lng > 180 {
+			helper.RespondError(w, r, apperror.BadRequest("pickup and dropoff must be valid coordinates"))
+			return
+		}
+		zones, err := h.AreaStore.Containing(ctxTimeout, geo.Point{Lat: pt.Lat, Lng: pt.Lng}, store.AreaKindZone)
+		if err != nil {
+			respondPromoError(w, r, err, "Failed to resolve zones")
+			return
+		}
+		trip.ZoneIDs = append(trip.ZoneIDs, zoneIDs(zones)...)
+	}
+	if p.FirstRideOnly {
+		paid, err := h.PromoStore.HasPaidRide(ctxTimeout, userID)
+		if err != nil {
+			respondPromoError(w, r, err, "Failed to check ride history")
+			return
+		}
+		trip.FirstRide = !paid
+	}
+	used, err := h.PromoStore.UserRedemptions(ctxTimeout, p.ID, userID)
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to check promo usage")
+		return
+	}
+
+	reason := ""
+	switch {
+	case !p.Live(time.Now()):
+		reason = store.ErrPromoInactive.Error()
+	case p.MaxRedemptions != nil && p.RedemptionCount >= *p.MaxRedemptions:
+		reason = store.ErrPromoExhausted.Error()
+	case used >= p.MaxPerUser:
+		reason = store.ErrPromoUserLimit.Error()
+	default:
+		if err := promo.Check(&p.Rules, trip); err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		helper.RespondJSON(w, r, http.StatusOK, map[string]any{
+			"code":   p.Code,
+			"valid":  false,
+			"reason": reason,
+		})
+		return
+	}
+
+	discount := promo.Discount(&p.Rules, body.FareCents)
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{
+		"code":             p.Code,
+		"valid":            true,
+		"discount_cents":   discount,
+		"fare_after_cents": body.FareCents - discount,
+	})
+}
+
+// HandleMyCredits returns the caller's credit balance and recent ledger entries.
+func (h *PromoHandler) HandleMyCredits(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+	h.respondCredits(w, r, userID)
+}
+
+func (h *PromoHandler) HandleUserCredits(w http.ResponseWriter, r *http.Request) {
+	userID, err := helper.URLParamUUID(r, "userID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid user id"))
+		return
+	}
+	h.respondCredits(w, r, userID)
+}
+
+func (h *PromoHandler) respondCredits(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	ctxTimeout, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	balance, err := h.CreditStore.Balance(ctxTimeout, userID)
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to load credit balance")
+		return
+	}
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.CreditStore.List(ctxTimeout, userID, limit, offset)
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to list credits")
+		return
+	}
+	entries := make([]map[string]any, 0, len(list))
+	for i := range list {
+		entries = append(entries, creditEntryResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{
+		"balance_cents": balance,
+		"entries":       entries,
+	})
+}
+
+// HandleAddCredit grants (positive) or claws back (negative) ride credit for a user.
+func (h *PromoHandler) HandleAddCredit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	userID, err := helper.URLParamUUID(r, "userID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid user id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		AmountCents int64  `json:"amount_cents"`
+		Note        string `json:"note"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		return
+	}
+	note := strings.TrimSpace(body.Note)
+	if body.AmountCents == 0 {
+		helper.RespondError(w, r, apperror.BadRequest("amount_cents must be non-zero"))
+		return
+	}
+	if note == "" || len(note) > 1000 {
+		helper.RespondError(w, r, apperror.BadRequest("note is required and must be at most 1000 characters"))
+		return
+	}
+	kind := store.CreditGrant
+	if body.AmountCents < 0 {
+		kind = store.CreditCorrection
+	}
+
+	e, balance, err := h.CreditStore.Add(ctxTimeout, &store.CreditEntry{
+		UserID:      userID,
+		Kind:        kind,
+		AmountCents: body.AmountCents,
+		Note:        sql.NullString{String: note, Valid: true},
+		CreatedBy:   &adminID,
+	})
+	if err != nil {
+		respondPromoError(w, r, err, "Failed to add credit")
+		return
+	}
+	logger.Audit(ctx, logger.AuditCreditAdjust, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"user_id":      userID,
+		"entry_id":     e.ID,
+		"amount_cents": e.AmountCents,
+	})
+	resp := creditEntryResponse(e)
+	resp["balance_cents"] = balance
+	helper.RespondJSON(w, r, http.StatusCreated, resp)
+}
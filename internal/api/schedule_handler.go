@@ -0,0 +1,562 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/availability"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	maxShiftLength   = 24 * time.Hour
+	maxTimeOffLength = 90 * 24 * time.Hour
+)
+
+type ScheduleHandler struct {
+	DriverStore store.DriverStore
+	ShiftStore  store.ShiftStore
+}
+
+func NewScheduleHandler(ds store.DriverStore, ss store.ShiftStore) *ScheduleHandler {
+	return &ScheduleHandler{ds, ss}
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int((d%time.Hour)/time.Minute))
+}
+
+func availabilityResponse(a *store.WeeklyAvailability) map[string]any {
+	return map[string]any{
+		"id":       a.ID,
+		"weekday":  int(a.Weekday),
+		"start":    formatClock(a.Start),
+		"end":      formatClock(a.End),
+		"timezone": a.Timezone,
+	}
+}
+
+func timeOffResponse(t *store.TimeOff) map[string]any {
+	resp := map[string]any{
+		"id":        t.ID,
+		"driver_id": t.DriverID,
+		"starts_at": t.StartsAt,
+		"ends_at":   t.EndsAt,
+	}
+	if t.Reason.Valid {
+		resp["reason"] = t.Reason.String
+	}
+	return resp
+}
+
+func shiftResponse(sh *store.Shift) map[string]any {
+	resp := map[string]any{
+		"id":         sh.ID,
+		"driver_id":  sh.DriverID,
+		"vehicle_id": sh.VehicleID,
+		"starts_at":  sh.StartsAt,
+		"ends_at":    sh.EndsAt,
+		"created_at": sh.CreatedAt,
+	}
+	if sh.Notes.Valid {
+		resp["notes"] = sh.Notes.String
+	}
+	return resp
+}
+
+func respondScheduleError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Driver, vehicle or schedule entry not found"))
+	case errors.Is(err, store.ErrShiftConflict):
+		helper.RespondError(w, r, apperror.Conflict("Shift overlaps an existing shift for this driver or vehicle"))
+	case errors.Is(err, store.ErrTimeOffConflict):
+		helper.RespondError(w, r, apperror.Conflict("Time off overlaps an existing entry"))
+	case errors.Is(err, store.ErrTimeOffOnShift):
+		helper.RespondError(w, r, apperror.Conflict("Time off overlaps a shift you are assigned to"))
+	case errors.Is(err, store.ErrDriverOnTimeOff):
+		helper.RespondError(w, r, apperror.Conflict("Driver has time off during this window"))
+	case errors.Is(err, store.ErrDriverNotApproved):
+		helper.RespondError(w, r, apperror.Conflict("Driver is not approved"))
+	case errors.Is(err, store.ErrVehicleUnavailable):
+		helper.RespondError(w, r, apperror.Conflict("Vehicle is not in service"))
+	case errors.Is(err, store.ErrInsuranceExpired):
+		helper.RespondError(w, r, apperror.Conflict("Vehicle insurance expires before the shift ends"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+// parseWindow reads an RFC3339 [start, end) pair and checks it is non-empty and no longer than max.
+func parseWindow(startStr, endStr string, max time.Duration) (time.Time, time.Time, string) {
+	start, err := time.Parse(time.RFC3339, startStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, "starts_at must be an RFC3339 timestamp"
+	}
+	end, err := time.Parse(time.RFC3339, endStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, "ends_at must be an RFC3339 timestamp"
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, "ends_at must be after starts_at"
+	}
+	if end.Sub(start) > max {
+		return time.Time{}, time.Time{}, fmt.Sprintf("window must not exceed %s", max)
+	}
+	return start, end, ""
+}
+
+func (h *ScheduleHandler) HandleSetAvailability(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		Slots []struct {
+			Weekday  *int   `json:"weekday"`
+			Start    string `json:"start"`
+			End      string `json:"end"`
+			Timezone string `json:"timezone"`
+		} `json:"slots"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse availability", "error", err)
+		return
+	}
+	if len(body.Slots) > 50 {
+		helper.RespondError(w, r, apperror.BadRequest("At most 50 slots are allowed"))
+		return
+	}
+
+	slots := make([]store.WeeklyAvailability, 0, len(body.Slots))
+	for i, s := range body.Slots {
+		if s.Weekday == nil || *s.Weekday < 0 || *s.Weekday > 6 {
+			helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("slots[%d].weekday must be 0 (Sunday) through 6", i)))
+			return
+		}
+		start, err := time.Parse("15:04", s.Start)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("slots[%d].start must be HH:MM", i)))
+			return
+		}
+		end, err := time.Parse("15:04", s.End)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("slots[%d].end must be HH:MM", i)))
+			return
+		}
+		if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+			helper.RespondError(w, r, apperror.BadRequest(fmt.Sprintf("slots[%d].timezone must be an IANA zone name", i)))
+			return
+		}
+		slots = append(slots, store.WeeklyAvailability{
+			Weekday:  time.Weekday(*s.Weekday),
+			Start:    time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+			End:      time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+			Timezone: s.Timezone,
+		})
+	}
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+
+	out, err := h.ShiftStore.ReplaceWeeklyAvailability(ctxTimeout, driver.ID, slots)
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to save availability")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverAvailability, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id": driver.ID,
+		"slots":     len(out),
+	})
+	respondAvailability(w, r, out)
+}
+
+func (h *ScheduleHandler) HandleGetAvailability(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	list, err := h.ShiftStore.ListWeeklyAvailability(ctxTimeout, driver.ID)
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to load availability")
+		return
+	}
+	respondAvailability(w, r, list)
+}
+
+func respondAvailability(w http.ResponseWriter, r *http.Request, list []store.WeeklyAvailability) {
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, availabilityResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *ScheduleHandler) HandleCreateTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		StartsAt string `json:"starts_at"`
+		EndsAt   string `json:"ends_at"`
+		Reason   string `json:"reason"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse time off", "error", err)
+		return
+	}
+	start, end, msg := parseWindow(body.StartsAt, body.EndsAt, maxTimeOffLength)
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+	if !end.After(time.Now()) {
+		helper.RespondError(w, r, apperror.BadRequest("Time off must end in the future"))
+		return
+	}
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+
+	out, err := h.ShiftStore.CreateTimeOff(ctxTimeout, &store.TimeOff{
+		DriverID: driver.ID,
+		StartsAt: start,
+		EndsAt:   end,
+		Reason:   store.ToNullString(strings.TrimSpace(body.Reason)),
+	})
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to create time off")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverTimeOff, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id":   driver.ID,
+		"time_off_id": out.ID,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, timeOffResponse(out))
+}
+
+func (h *ScheduleHandler) HandleListTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	list, err := h.ShiftStore.ListTimeOff(ctxTimeout, driver.ID, time.Now())
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to list time off")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, timeOffResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *ScheduleHandler) HandleDeleteTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+	timeOffID, err := helper.URLParamUUID(r, "timeOffID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid time off id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	if err := h.ShiftStore.DeleteTimeOff(ctxTimeout, driver.ID, timeOffID); err != nil {
+		respondScheduleError(w, r, err, "Failed to delete time off")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverTimeOff, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id":   driver.ID,
+		"time_off_id": timeOffID,
+		"action":      "delete",
+	})
+	helper.RespondMessage(w, r, http.StatusOK, "Time off deleted")
+}
+
+func (h *ScheduleHandler) HandleSetDuty(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		OnDuty *bool `json:"on_duty"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse duty toggle", "error", err)
+		return
+	}
+	if body.OnDuty == nil {
+		helper.RespondError(w, r, apperror.BadRequest("on_duty is required"))
+		return
+	}
+
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	out, err := h.DriverStore.SetOnDuty(ctxTimeout, driver.ID, *body.OnDuty)
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to update duty status")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverDuty, &userID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"driver_id": driver.ID,
+		"on_duty":   out.OnDuty,
+	})
+	resp := map[string]any{"driver_id": out.ID, "on_duty": out.OnDuty}
+	if out.DutyChangedAt.Valid {
+		resp["duty_changed_at"] = out.DutyChangedAt.Time
+	}
+	helper.RespondJSON(w, r, http.StatusOK, resp)
+}
+
+// HandleMyShifts lists the caller's shifts for the next 14 days unless ?from= and ?to= are given.
+func (h *ScheduleHandler) HandleMyShifts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	from, to, msg := shiftRange(r)
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+	driver, ok := currentDriver(ctxTimeout, w, r, h.DriverStore)
+	if !ok {
+		return
+	}
+	h.respondShifts(ctxTimeout, w, r, &driver.ID, from, to)
+}
+
+func shiftRange(r *http.Request) (time.Time, time.Time, string) {
+	from := time.Now()
+	to := from.Add(14 * 24 * time.Hour)
+	if s := r.URL.Query().Get("from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return from, to, "from must be an RFC3339 timestamp"
+		}
+		from, to = t, t.Add(14*24*time.Hour)
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return from, to, "to must be an RFC3339 timestamp"
+		}
+		to = t
+	}
+	if !to.After(from) {
+		return from, to, "to must be after from"
+	}
+	return from, to, ""
+}
+
+func (h *ScheduleHandler) respondShifts(ctx context.Context, w http.ResponseWriter, r *http.Request, driverID *uuid.UUID, from, to time.Time) {
+	list, err := h.ShiftStore.ListShifts(ctx, driverID, from, to)
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to list shifts")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, shiftResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *ScheduleHandler) HandleCreateShift(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		DriverID  uuid.UUID  `json:"driver_id"`
+		VehicleID *uuid.UUID `json:"vehicle_id"`
+		StartsAt  string     `json:"starts_at"`
+		EndsAt    string     `json:"ends_at"`
+		Notes     string     `json:"notes"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse shift", "error", err)
+		return
+	}
+	if body.DriverID == uuid.Nil {
+		helper.RespondError(w, r, apperror.BadRequest("driver_id is required"))
+		return
+	}
+	start, end, msg := parseWindow(body.StartsAt, body.EndsAt, maxShiftLength)
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+
+	out, err := h.ShiftStore.CreateShift(ctxTimeout, &store.Shift{
+		DriverID:  body.DriverID,
+		VehicleID: body.VehicleID,
+		StartsAt:  start,
+		EndsAt:    end,
+		Notes:     store.ToNullString(strings.TrimSpace(body.Notes)),
+		CreatedBy: &adminID,
+	})
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to create shift")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverShift, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"shift_id":   out.ID,
+		"driver_id":  out.DriverID,
+		"vehicle_id": out.VehicleID,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, shiftResponse(out))
+}
+
+// HandleListShifts lists fleet shifts in a window, optionally narrowed with ?driver_id=.
+func (h *ScheduleHandler) HandleListShifts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	from, to, msg := shiftRange(r)
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+	var driverID *uuid.UUID
+	if s := r.URL.Query().Get("driver_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+			return
+		}
+		driverID = &id
+	}
+	h.respondShifts(ctxTimeout, w, r, driverID, from, to)
+}
+
+func (h *ScheduleHandler) HandleDeleteShift(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	shiftID, err := helper.URLParamUUID(r, "shiftID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid shift id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := h.ShiftStore.DeleteShift(ctxTimeout, shiftID); err != nil {
+		respondScheduleError(w, r, err, "Failed to delete shift")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditDriverShift, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"shift_id": shiftID,
+		"action":   "delete",
+	})
+	helper.RespondMessage(w, r, http.StatusOK, "Shift deleted")
+}
+
+// HandleAvailableDrivers lists drivers whose weekly availability covers ?starts_at..?ends_at and who
+// have no time off or shift in that window; ?class= limits to drivers holding a vehicle of that class.
+func (h *ScheduleHandler) HandleAvailableDrivers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	start, end, msg := parseWindow(q.Get("starts_at"), q.Get("ends_at"), maxShiftLength)
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+	class := q.Get("class")
+	if class != "" && !store.ValidVehicleClass(class) {
+		helper.RespondError(w, r, apperror.BadRequest("class must be escalade, suburban or sprinter"))
+		return
+	}
+
+	candidates, err := h.ShiftStore.FindCandidates(ctxTimeout, start, end, class)
+	if err != nil {
+		respondScheduleError(w, r, err, "Failed to find available drivers")
+		return
+	}
+
+	out := make([]map[string]any, 0, len(candidates))
+	for i := range candidates {
+		c := &candidates[i]
+		windows := make([]availability.WeeklyWindow, 0, len(c.Availability))
+		for _, a := range c.Availability {
+			loc, err := time.LoadLocation(a.Timezone)
+			if err != nil {
+				logger.Warn(ctx, "skipping availability slot with unknown timezone", "driver_id", c.Driver.ID, "timezone", a.Timezone)
+				continue
+			}
+			windows = append(windows, availability.WeeklyWindow{Weekday: a.Weekday, Start: a.Start, End: a.End, Location: loc})
+		}
+		if !availability.Covers(windows, start, end) {
+			continue
+		}
+		entry := map[string]any{
+			"driver_id":  c.Driver.ID,
+			"first_name": c.Driver.FirstName,
+			"last_name":  c.Driver.LastName,
+			"home_base":  c.Driver.HomeBase,
+			"rating":     c.Driver.Rating,
+			"on_duty":    c.Driver.OnDuty,
+			"vehicle_id": c.VehicleID,
+		}
+		if c.VehicleClass.Valid {
+			entry["vehicle_class"] = c.VehicleClass.String
+		}
+		out = append(out, entry)
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/tax"
+	"github.com/google/uuid"
+)
+
+var taxCodePattern = regexp.MustCompile(`^[A-Z0-9_]{2,50}$`)
+
+type TaxHandler struct {
+	TaxStore store.TaxStore
+}
+
+func NewTaxHandler(ts store.TaxStore) *TaxHandler {
+	return &TaxHandler{ts}
+}
+
+func taxRuleResponse(tr *store.TaxRule) map[string]any {
+	resp := map[string]any{
+		"id":             tr.ID,
+		"area_id":        tr.AreaID,
+		"code":           tr.Code,
+		"name":           tr.Name,
+		"kind":           tr.Kind,
+		"applies_on":     tr.AppliesOn,
+		"effective_from": tr.EffectiveFrom.Format(time.DateOnly),
+		"effective_to":   nil,
+		"created_at":     tr.CreatedAt,
+		"updated_at":     tr.UpdatedAt,
+	}
+	if tr.Kind == store.TaxKindPercentage {
+		resp["rate_bps"] = tr.RateBps
+	} else {
+		resp["amount_cents"] = tr.AmountCents
+	}
+	if tr.EffectiveTo != nil {
+		resp["effective_to"] = tr.EffectiveTo.Format(time.DateOnly)
+	}
+	return resp
+}
+
+func taxLinesResponse(lines []tax.Line) []map[string]any {
+	out := make([]map[string]any, 0, len(lines))
+	for _, l := range lines {
+		line := map[string]any{
+			"rule_id":      l.RuleID,
+			"code":         l.Code,
+			"name":         l.Name,
+			"amount_cents": l.AmountCents,
+		}
+		if l.Kind == store.TaxKindPercentage {
+			line["rate_bps"] = l.RateBps
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func respondTaxError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Tax rule or jurisdiction not found"))
+	case errors.Is(err, store.ErrTaxRuleOverlap):
+		helper.RespondError(w, r, apperror.Conflict("Another version of this tax is in effect for part of that period"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+type taxRuleBody struct {
+	AreaID        uuid.UUID `json:"area_id"`
+	Code          string    `json:"code"`
+	Name          string    `json:"name"`
+	Kind          string    `json:"kind"`
+	RateBps       int       `json:"rate_bps"`
+	AmountCents   int       `json:"amount_cents"`
+	AppliesOn     string    `json:"applies_on"`
+	EffectiveFrom string    `json:"effective_from"`
+	EffectiveTo   *string   `json:"effective_to"`
+}
+
+// rule validates the fields shared by create and update; area and code are checked by the caller.
+func (b *taxRuleBody) rule() (*store.TaxRule, string) {
+	tr := &store.TaxRule{
+		AreaID:    b.AreaID,
+		Code:      strings.ToUpper(strings.TrimSpace(b.Code)),
+		Name:      strings.TrimSpace(b.Name),
+		Kind:      b.Kind,
+		AppliesOn: b.AppliesOn,
+	}
+	if tr.Name == "" || len(tr.Name) > 255 {
+		return nil, "name is required and must be at most 255 characters"
+	}
+	switch tr.Kind {
+	case store.TaxKindPercentage:
+		if b.RateBps <= 0 || b.RateBps > 10000 {
+			return nil, "rate_bps must be between 1 and 10000 for percentage taxes"
+		}
+		tr.RateBps = b.RateBps
+	case store.TaxKindFlat:
+		if b.AmountCents <= 0 {
+			return nil, "amount_cents must be positive for flat taxes"
+		}
+		tr.AmountCents = b.AmountCents
+	default:
+		return nil, "kind must be percentage or flat"
+	}
+	if tr.AppliesOn == "" {
+		tr.AppliesOn = store.TaxOnPickup
+	}
+	if tr.AppliesOn != store.TaxOnPickup && tr.AppliesOn != store.TaxOnDropoff && tr.AppliesOn != store.TaxOnEither {
+		return nil, "applies_on must be pickup, dropoff or either"
+	}
+
+	from, err := time.Parse(time.DateOnly, b.EffectiveFrom)
+	if err != nil {
+		return nil, "effective_from must be a date (YYYY-MM-DD)"
+	}
+	tr.EffectiveFrom = from
+	if b.EffectiveTo != nil && *b.EffectiveTo != "" {
+		to, err := time.Parse(time.DateOnly, *b.EffectiveTo)
+		if err != nil {
+			return nil, "effective_to must be a date (YYYY-MM-DD)"
+		}
+		if !to.After(from) {
+			return nil, "effective_to must be after effective_from"
+		}
+		tr.EffectiveTo = &to
+	}
+	return tr, ""
+}
+
+func (h *TaxHandler) audit(r *http.Request, adminID uuid.UUID, action string, tr *store.TaxRule) {
+	logger.Audit(r.Context(), logger.AuditTaxRule, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"rule_id": tr.ID,
+		"area_id": tr.AreaID,
+		"code":    tr.Code,
+		"action":  action,
+	})
+}
+
+func (h *TaxHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body taxRuleBody
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse tax rule", "error", err)
+		return
+	}
+	if body.AreaID == uuid.Nil {
+		helper.RespondError(w, r, apperror.BadRequest("area_id is required"))
+		return
+	}
+	tr, msg := body.rule()
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+	if !taxCodePattern.MatchString(tr.Code) {
+		helper.RespondError(w, r, apperror.BadRequest("code must be 2-50 letters, digits or underscores"))
+		return
+	}
+
+	out, err := h.TaxStore.Create(ctxTimeout, tr)
+	if err != nil {
+		respondTaxError(w, r, err, "Failed to create tax rule")
+		return
+	}
+	h.audit(r, adminID, "create", out)
+	helper.RespondJSON(w, r, http.StatusCreated, taxRuleResponse(out))
+}
+
+// HandleUpdate replaces a rule's rates, trip end and dates. Jurisdiction and code are fixed; a tax
+// that changes rate on a date is modelled by ending this rule and creating a new version.
+func (h *TaxHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	ruleID, err := helper.URLParamUUID(r, "ruleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid tax rule id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body taxRuleBody
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse tax rule", "error", err)
+		return
+	}
+	tr, msg := body.rule()
+	if msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+	tr.ID = ruleID
+
+	out, err := h.TaxStore.Update(ctxTimeout, tr)
+	if err != nil {
+		respondTaxError(w, r, err, "Failed to update tax rule")
+		return
+	}
+	h.audit(r, adminID, "update", out)
+	helper.RespondJSON(w, r, http.StatusOK, taxRuleResponse(out))
+}
+
+func (h *TaxHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	areaID := uuid.Nil
+	if v := r.URL.Query().Get("area_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest("Invalid area_id"))
+			return
+		}
+		areaID = id
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	list, err := h.TaxStore.List(ctxTimeout, areaID)
+	if err != nil {
+		respondTaxError(w, r, err, "Failed to list tax rules")
+		return
+	}
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, taxRuleResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *TaxHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	ruleID, err := helper.URLParamUUID(r, "ruleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid tax rule id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tr, err := h.TaxStore.Get(ctxTimeout, ruleID)
+	if err != nil {
+		respondTaxError(w, r, err, "Failed to load tax rule")
+		return
+	}
+	if err := h.TaxStore.Delete(ctxTimeout, ruleID); err != nil {
+		respondTaxError(w, r, err, "Failed to delete tax rule")
+		return
+	}
+	h.audit(r, adminID, "delete", tr)
+	helper.RespondMessage(w, r, http.StatusOK, "Tax rule deleted")
+}
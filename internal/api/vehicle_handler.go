@@ -0,0 +1,496 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/middleware"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+type VehicleHandler struct {
+	VehicleStore store.VehicleStore
+}
+
+func NewVehicleHandler(vs store.VehicleStore) *VehicleHandler {
+	return &VehicleHandler{vs}
+}
+
+func vehicleResponse(v *store.Vehicle) map[string]any {
+	return map[string]any{
+		"id":                 v.ID,
+		"vin":                v.VIN,
+		"plate":              v.Plate,
+		"plate_state":        v.PlateState,
+		"make":               v.Make,
+		"model":              v.Model,
+		"year":               v.Year,
+		"class":              v.Class,
+		"passenger_capacity": v.PassengerCapacity,
+		"luggage_capacity":   v.LuggageCapacity,
+		"color":              v.Color,
+		"amenities":          v.Amenities,
+		"insurance_expiry":   v.InsuranceExpiry.Format(time.DateOnly),
+		"status":             v.Status,
+		"odometer_miles":     v.OdometerMiles,
+		"created_at":         v.CreatedAt,
+		"updated_at":         v.UpdatedAt,
+	}
+}
+
+func assignmentResponse(a *store.VehicleAssignment) map[string]any {
+	resp := map[string]any{
+		"id":          a.ID,
+		"vehicle_id":  a.VehicleID,
+		"driver_id":   a.DriverID,
+		"assigned_at": a.AssignedAt,
+		"assigned_by": a.AssignedBy,
+		"current":     !a.UnassignedAt.Valid,
+	}
+	if a.UnassignedAt.Valid {
+		resp["unassigned_at"] = a.UnassignedAt.Time
+		resp["unassigned_by"] = a.UnassignedBy
+	}
+	if a.Notes.Valid {
+		resp["notes"] = a.Notes.String
+	}
+	return resp
+}
+
+type vehicleInput struct {
+	VIN               *string   `json:"vin"`
+	Plate             *string   `json:"plate"`
+	PlateState        *string   `json:"plate_state"`
+	Make              *string   `json:"make"`
+	Model             *string   `json:"model"`
+	Year              *int      `json:"year"`
+	Class             *string   `json:"class"`
+	PassengerCapacity *int      `json:"passenger_capacity"`
+	LuggageCapacity   *int      `json:"luggage_capacity"`
+	Color             *string   `json:"color"`
+	Amenities         *[]string `json:"amenities"`
+	InsuranceExpiry   *string   `json:"insurance_expiry"`
+	Status            *string   `json:"status"`
+}
+
+// apply copies the provided fields onto v, returning a client-facing message for the first invalid one.
+func (in *vehicleInput) apply(v *store.Vehicle) string {
+	if in.Plate != nil {
+		v.Plate = *in.Plate
+	}
+	if in.PlateState != nil {
+		v.PlateState = *in.PlateState
+	}
+	if in.Make != nil {
+		v.Make = *in.Make
+	}
+	if in.Model != nil {
+		v.Model = *in.Model
+	}
+	if in.Year != nil {
+		v.Year = *in.Year
+	}
+	if in.Class != nil {
+		v.Class = strings.ToLower(strings.TrimSpace(*in.Class))
+	}
+	if in.PassengerCapacity != nil {
+		v.PassengerCapacity = *in.PassengerCapacity
+	}
+	if in.LuggageCapacity != nil {
+		v.LuggageCapacity = *in.LuggageCapacity
+	}
+	if in.Color != nil {
+		v.Color = *in.Color
+	}
+	if in.Amenities != nil {
+		v.Amenities = *in.Amenities
+	}
+	if in.InsuranceExpiry != nil {
+		t, err := time.Parse(time.DateOnly, strings.TrimSpace(*in.InsuranceExpiry))
+		if err != nil {
+			return "insurance_expiry must be a date in YYYY-MM-DD format"
+		}
+		v.InsuranceExpiry = t
+	}
+	if in.Status != nil {
+		v.Status = *in.Status
+	}
+
+	for _, f := range []struct{ name, value string }{
+		{"plate", v.Plate},
+		{"plate_state", v.PlateState},
+		{"make", v.Make},
+		{"model", v.Model},
+		{"color", v.Color},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			return f.name + " is required"
+		}
+	}
+	if v.Year < 1980 || v.Year > time.Now().Year()+1 {
+		return "year is out of range"
+	}
+	if !store.ValidVehicleClass(v.Class) {
+		return "class must be one of escalade, suburban, sprinter"
+	}
+	if v.PassengerCapacity < 1 || v.PassengerCapacity > 20 {
+		return "passenger_capacity must be between 1 and 20"
+	}
+	if v.LuggageCapacity < 0 || v.LuggageCapacity > 30 {
+		return "luggage_capacity must be between 0 and 30"
+	}
+	if v.InsuranceExpiry.IsZero() {
+		return "insurance_expiry is required"
+	}
+	switch v.Status {
+	case store.VehicleStatusActive, store.VehicleStatusOutOfService:
+	default:
+		return "status must be one of active, out_of_service"
+	}
+	return ""
+}
+
+func respondVehicleStoreError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		helper.RespondError(w, r, apperror.NotFound("Vehicle not found"))
+	case errors.Is(err, store.ErrDuplicateVehicle):
+		helper.RespondError(w, r, apperror.Conflict("VIN or plate is already registered"))
+	case errors.Is(err, store.ErrVehicleRetired):
+		helper.RespondError(w, r, apperror.Conflict("Retired vehicles cannot be modified"))
+	case errors.Is(err, store.ErrVehicleInShop):
+		helper.RespondError(w, r, apperror.Conflict("Vehicle status is managed by its in-progress maintenance"))
+	default:
+		helper.RespondError(w, r, apperror.InternalError(msg, err))
+		logger.Error(r.Context(), strings.ToLower(msg), "error", err)
+	}
+}
+
+func (h *VehicleHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body vehicleInput
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse vehicle request", "error", err)
+		return
+	}
+	if body.VIN == nil || !helper.ValidVIN(*body.VIN) {
+		helper.RespondError(w, r, apperror.BadRequest("vin is not a valid 17 character VIN"))
+		return
+	}
+
+	v := &store.Vehicle{VIN: helper.NormalizeVIN(*body.VIN), Status: store.VehicleStatusActive}
+	if msg := body.apply(v); msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+
+	out, err := h.VehicleStore.Create(ctxTimeout, v)
+	if err != nil {
+		respondVehicleStoreError(w, r, err, "Failed to create vehicle")
+		return
+	}
+	logger.Info(ctx, "vehicle created", "vehicle_id", out.ID, "admin_id", adminID)
+	logger.Audit(ctx, logger.AuditVehicleCreate, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": out.ID,
+		"vin":        out.VIN,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, vehicleResponse(out))
+}
+
+func (h *VehicleHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	class := r.URL.Query().Get("class")
+	if class != "" && !store.ValidVehicleClass(class) {
+		helper.RespondError(w, r, apperror.BadRequest("class must be one of escalade, suburban, sprinter"))
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.VehicleStatusActive, store.VehicleStatusOutOfService, store.VehicleStatusRetired:
+	default:
+		helper.RespondError(w, r, apperror.BadRequest("status must be one of active, out_of_service, retired"))
+		return
+	}
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+
+	vehicles, err := h.VehicleStore.List(ctxTimeout, class, status, limit, offset)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to list vehicles", err))
+		logger.Error(ctx, "failed to list vehicles", "error", err)
+		return
+	}
+	out := make([]map[string]any, 0, len(vehicles))
+	for i := range vehicles {
+		out = append(out, vehicleResponse(&vehicles[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
+
+func (h *VehicleHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	v, err := h.VehicleStore.GetByID(ctxTimeout, id)
+	if err != nil {
+		respondVehicleStoreError(w, r, err, "Failed to load vehicle")
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, vehicleResponse(v))
+}
+
+func (h *VehicleHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body vehicleInput
+	if err := helper.DecodeJSON(w, r, &body); err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		logger.Error(ctx, "failed to parse vehicle request", "error", err)
+		return
+	}
+
+	v, err := h.VehicleStore.GetByID(ctxTimeout, id)
+	if err != nil {
+		respondVehicleStoreError(w, r, err, "Failed to load vehicle")
+		return
+	}
+	if v.Status == store.VehicleStatusRetired {
+		helper.RespondError(w, r, apperror.Conflict("Retired vehicles cannot be modified"))
+		return
+	}
+	if body.VIN != nil && helper.NormalizeVIN(*body.VIN) != v.VIN {
+		helper.RespondError(w, r, apperror.BadRequest("vin cannot be changed"))
+		return
+	}
+	if msg := body.apply(v); msg != "" {
+		helper.RespondError(w, r, apperror.BadRequest(msg))
+		return
+	}
+
+	out, err := h.VehicleStore.Update(ctxTimeout, v, adminID)
+	if err != nil {
+		respondVehicleStoreError(w, r, err, "Failed to update vehicle")
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditVehicleUpdate, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": out.ID,
+		"status":     out.Status,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, vehicleResponse(out))
+}
+
+// HandleRetire soft-deletes a vehicle so its assignment history survives.
+func (h *VehicleHandler) HandleRetire(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	id, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	v, err := h.VehicleStore.Retire(ctxTimeout, id, adminID)
+	if err != nil {
+		respondVehicleStoreError(w, r, err, "Failed to retire vehicle")
+		return
+	}
+
+	logger.Info(ctx, "vehicle retired", "vehicle_id", v.ID, "admin_id", adminID)
+	logger.Audit(ctx, logger.AuditVehicleRetire, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id": v.ID,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, vehicleResponse(v))
+}
+
+func (h *VehicleHandler) HandleAssign(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var body struct {
+		DriverID uuid.UUID `json:"driver_id"`
+		Notes    string    `json:"notes"`
+	}
+	if err := helper.DecodeJSON(w, r, &body); err != nil || body.DriverID == uuid.Nil {
+		helper.RespondError(w, r, apperror.BadRequest("driver_id is required"))
+		return
+	}
+
+	a, err := h.VehicleStore.Assign(ctxTimeout, vehicleID, body.DriverID, adminID, strings.TrimSpace(body.Notes))
+	if err != nil {
+		logger.Audit(ctx, logger.AuditVehicleAssign, &adminID, helper.ClientIP(r), r.UserAgent(), false, map[string]any{
+			"vehicle_id": vehicleID,
+			"driver_id":  body.DriverID,
+			"reason":     err.Error(),
+		})
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			helper.RespondError(w, r, apperror.NotFound("Vehicle or driver not found"))
+		case errors.Is(err, store.ErrVehicleUnavailable):
+			helper.RespondError(w, r, apperror.Conflict("Vehicle is out of service"))
+		case errors.Is(err, store.ErrInsuranceExpired):
+			helper.RespondError(w, r, apperror.Conflict("Vehicle insurance has expired"))
+		case errors.Is(err, store.ErrDriverNotApproved):
+			helper.RespondError(w, r, apperror.Conflict("Driver is not approved"))
+		default:
+			helper.RespondError(w, r, apperror.InternalError("Failed to assign vehicle", err))
+			logger.Error(ctx, "failed to assign vehicle", "vehicle_id", vehicleID, "driver_id", body.DriverID, "error", err)
+		}
+		return
+	}
+
+	logger.Info(ctx, "vehicle assigned", "vehicle_id", vehicleID, "driver_id", body.DriverID, "admin_id", adminID)
+	logger.Audit(ctx, logger.AuditVehicleAssign, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id":    vehicleID,
+		"driver_id":     body.DriverID,
+		"assignment_id": a.ID,
+	})
+	helper.RespondJSON(w, r, http.StatusCreated, assignmentResponse(a))
+}
+
+func (h *VehicleHandler) HandleUnassign(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := middleware.GetUserID(ctx)
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	a, err := h.VehicleStore.Unassign(ctxTimeout, vehicleID, adminID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotAssigned) {
+			helper.RespondError(w, r, apperror.NotFound("Vehicle has no current assignment"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to unassign vehicle", err))
+		logger.Error(ctx, "failed to unassign vehicle", "vehicle_id", vehicleID, "error", err)
+		return
+	}
+
+	logger.Audit(ctx, logger.AuditVehicleUnassign, &adminID, helper.ClientIP(r), r.UserAgent(), true, map[string]any{
+		"vehicle_id":    vehicleID,
+		"driver_id":     a.DriverID,
+		"assignment_id": a.ID,
+	})
+	helper.RespondJSON(w, r, http.StatusOK, assignmentResponse(a))
+}
+
+// HandleVehicleAssignments lists a vehicle's history, or with ?at=RFC3339 the single assignment open at that instant.
+func (h *VehicleHandler) HandleVehicleAssignments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vehicleID, err := helper.URLParamUUID(r, "vehicleID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid vehicle id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		at, err := time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			helper.RespondError(w, r, apperror.BadRequest("at must be an RFC3339 timestamp"))
+			return
+		}
+		a, err := h.VehicleStore.AssignmentAt(ctxTimeout, vehicleID, at)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				helper.RespondError(w, r, apperror.NotFound("No assignment at that time"))
+				return
+			}
+			helper.RespondError(w, r, apperror.InternalError("Failed to load assignment", err))
+			logger.Error(ctx, "failed to load assignment", "vehicle_id", vehicleID, "error", err)
+			return
+		}
+		helper.RespondJSON(w, r, http.StatusOK, assignmentResponse(a))
+		return
+	}
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.VehicleStore.ListAssignmentsByVehicle(ctxTimeout, vehicleID, limit, offset)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to list assignments", err))
+		logger.Error(ctx, "failed to list vehicle assignments", "vehicle_id", vehicleID, "error", err)
+		return
+	}
+	respondAssignments(w, r, list)
+}
+
+func (h *VehicleHandler) HandleDriverAssignments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	driverID, err := helper.URLParamUUID(r, "driverID")
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid driver id"))
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	limit := helper.QueryInt(r, "limit", 50, 1, 200)
+	offset := helper.QueryInt(r, "offset", 0, 0, 1<<31-1)
+	list, err := h.VehicleStore.ListAssignmentsByDriver(ctxTimeout, driverID, limit, offset)
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to list assignments", err))
+		logger.Error(ctx, "failed to list driver assignments", "driver_id", driverID, "error", err)
+		return
+	}
+	respondAssignments(w, r, list)
+}
+
+func respondAssignments(w http.ResponseWriter, r *http.Request, list []store.VehicleAssignment) {
+	out := make([]map[string]any, 0, len(list))
+	for i := range list {
+		out = append(out, assignmentResponse(&list[i]))
+	}
+	helper.RespondJSON(w, r, http.StatusOK, out)
+}
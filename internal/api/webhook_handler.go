@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/webhooks"
+)
+
+const maxWebhookBody = 1 << 20
+
+type WebhookHandler struct {
+	EventStore store.PaymentEventStore
+	Processor  *webhooks.Processor
+	// Provider is the configured gateway name; events are stored and matched to intents under it.
+	Provider string
+	Secret   []byte
+}
+
+func NewWebhookHandler(es store.PaymentEventStore, p *webhooks.Processor, provider string, secret []byte) *WebhookHandler {
+	return &WebhookHandler{es, p, provider, secret}
+}
+
+// HandlePaymentWebhook verifies and stores a provider event, then applies it. Events already applied
+// are acknowledged without reprocessing; events whose processing failed are retried when the provider
+// redelivers them, and can also be replayed from the stored copy.
+func (h *WebhookHandler) HandlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if len(h.Secret) == 0 {
+		helper.RespondError(w, r, apperror.New(apperror.CodeInternalError, "Payment webhooks are not configured", http.StatusServiceUnavailable))
+		logger.Error(ctx, "payment webhook received but PAYMENT_WEBHOOK_SECRET is not set")
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Invalid request body"))
+		return
+	}
+
+	if err := payments.VerifySignature(payload, r.Header.Get(payments.WebhookSignatureHeader), h.Secret, payments.WebhookTolerance, time.Now()); err != nil {
+		logger.Warn(ctx, "rejected payment webhook", "error", err, "ip", helper.ClientIP(r))
+		helper.RespondError(w, r, apperror.BadRequest("Invalid webhook signature"))
+		return
+	}
+
+	ev, err := payments.ParseEvent(payload)
+	if err != nil {
+		helper.RespondError(w, r, apperror.BadRequest("Malformed webhook event"))
+		logger.Warn(ctx, "malformed payment webhook", "error", err)
+		return
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stored, inserted, err := h.EventStore.Record(ctxTimeout, &store.PaymentEvent{
+		Provider:  h.Provider,
+		EventID:   ev.ID,
+		EventType: ev.Type,
+		Payload:   payload,
+	})
+	if err != nil {
+		helper.RespondError(w, r, apperror.InternalError("Failed to store webhook event", err))
+		logger.Error(ctx, "failed to store webhook event", "error", err, "event_id", ev.ID)
+		return
+	}
+	if !inserted && stored.ProcessedAt.Valid {
+		logger.Debug(ctx, "duplicate payment webhook", "event_id", ev.ID)
+		helper.RespondJSON(w, r, http.StatusOK, map[string]any{"received": true, "duplicate": true})
+		return
+	}
+
+	if err := h.Processor.Process(ctxTimeout, stored); err != nil {
+		// A non-2xx makes the provider redeliver; the stored event keeps the error for inspection.
+		logger.Error(ctx, "failed to process webhook event", "error", err, "event_id", ev.ID, "type", ev.Type)
+		if errors.Is(err, payments.ErrMalformedEvent) {
+			helper.RespondError(w, r, apperror.BadRequest("Malformed webhook event"))
+			return
+		}
+		helper.RespondError(w, r, apperror.InternalError("Failed to process webhook event", err))
+		return
+	}
+	helper.RespondJSON(w, r, http.StatusOK, map[string]any{"received": true})
+}
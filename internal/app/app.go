@@ -2,21 +2,48 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/diagnosis/luxsuv-api-v2/internal/adjustments"
 	"github.com/diagnosis/luxsuv-api-v2/internal/api"
+	"github.com/diagnosis/luxsuv-api-v2/internal/blob"
+	"github.com/diagnosis/luxsuv-api-v2/internal/jobs"
 	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
 	"github.com/diagnosis/luxsuv-api-v2/internal/secure"
 	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/tracking"
+	"github.com/diagnosis/luxsuv-api-v2/internal/webhooks"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Application struct {
-	DB            *pgxpool.Pool
-	Signer        *secure.Signer
-	HealthHandler *api.HealthHandler
-	UserHandler   *api.UserHandler
+	DB                 *pgxpool.Pool
+	Signer             *secure.Signer
+	IdempotencyStore   store.IdempotencyStore
+	HealthHandler      *api.HealthHandler
+	UserHandler        *api.UserHandler
+	DriverHandler      *api.DriverHandler
+	DocumentHandler    *api.DriverDocumentHandler
+	VehicleHandler     *api.VehicleHandler
+	MaintenanceHandler *api.MaintenanceHandler
+	ScheduleHandler    *api.ScheduleHandler
+	LocationHandler    *api.LocationHandler
+	AreaHandler        *api.AreaHandler
+	TaxHandler         *api.TaxHandler
+	PaymentHandler     *api.PaymentHandler
+	WebhookHandler     *api.WebhookHandler
+	AdjustmentHandler  *api.AdjustmentHandler
+	PromoHandler       *api.PromoHandler
+
+	MaintenanceMonitor  *jobs.MaintenanceMonitor
+	LocationMaintainer  *jobs.LocationMaintainer
+	IdempotencySweeper  *jobs.IdempotencySweeper
+	AdjustmentRecoverer *jobs.AdjustmentRecoverer
 }
 
 func NewApplication(pool *pgxpool.Pool) (*Application, error) {
@@ -27,6 +54,21 @@ func NewApplication(pool *pgxpool.Pool) (*Application, error) {
 
 	userStore := store.NewPostgresUserStore(pool)
 	refreshTokenStore := store.NewPostgresRefreshTokenStore(pool)
+	driverStore := store.NewPostgresDriverStore(pool)
+	driverDocumentStore := store.NewPostgresDriverDocumentStore(pool)
+	vehicleStore := store.NewPostgresVehicleStore(pool)
+	maintenanceStore := store.NewPostgresMaintenanceStore(pool)
+	shiftStore := store.NewPostgresShiftStore(pool)
+	locationStore := store.NewPostgresLocationStore(pool)
+	areaStore := store.NewPostgresAreaStore(pool)
+	taxStore := store.NewPostgresTaxStore(pool)
+	paymentStore := store.NewPostgresPaymentStore(pool)
+	idempotencyStore := store.NewPostgresIdempotencyStore(pool)
+	paymentEventStore := store.NewPostgresPaymentEventStore(pool)
+	adjustmentStore := store.NewPostgresAdjustmentStore(pool)
+	promoStore := store.NewPostgresPromoStore(pool)
+	creditStore := store.NewPostgresCreditStore(pool)
+	locationIndex := tracking.NewIndex(10 * time.Minute)
 	accessSecret := []byte(os.Getenv("JWT_ACCESS_SECRET"))
 	refreshSecret := []byte(os.Getenv("JWT_REFRESH_SECRET"))
 	issuer := os.Getenv("TOKEN_ISSUER")
@@ -44,12 +86,131 @@ func NewApplication(pool *pgxpool.Pool) (*Application, error) {
 	}
 	logger.Info(ctx, "JWT signer initialized", "issuer", issuer, "audience", audience)
 
+	urlSigner, err := secure.NewURLSigner([]byte(os.Getenv("DOCUMENT_URL_SECRET")))
+	if err != nil {
+		logger.Error(ctx, "DOCUMENT_URL_SECRET is invalid", "error", err)
+		return nil, err
+	}
+
+	blobStore, err := newBlobStore()
+	if err != nil {
+		logger.Error(ctx, "failed to initialize blob storage", "error", err)
+		return nil, err
+	}
+
+	gateway, provider, err := newPaymentGateway()
+	if err != nil {
+		logger.Error(ctx, "failed to initialize payment gateway", "error", err)
+		return nil, err
+	}
+
 	userHandler := api.NewUserHandler(userStore, signer, refreshTokenStore)
+	driverHandler := api.NewDriverHandler(driverStore)
+	documentHandler := api.NewDriverDocumentHandler(driverStore, driverDocumentStore, blobStore, urlSigner)
+	vehicleHandler := api.NewVehicleHandler(vehicleStore)
+	maintenanceHandler := api.NewMaintenanceHandler(maintenanceStore, vehicleStore)
+	scheduleHandler := api.NewScheduleHandler(driverStore, shiftStore)
+	locationHandler := api.NewLocationHandler(driverStore, locationStore, locationIndex)
+	areaHandler := api.NewAreaHandler(areaStore, taxStore)
+	taxHandler := api.NewTaxHandler(taxStore)
+	paymentHandler := api.NewPaymentHandler(paymentStore, userStore, gateway, provider)
+	webhookSecret := []byte(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+	if len(webhookSecret) == 0 {
+		logger.Warn(ctx, "PAYMENT_WEBHOOK_SECRET is not set; payment webhooks will be rejected")
+	}
+	webhookHandler := api.NewWebhookHandler(paymentEventStore, webhooks.NewProcessor(paymentStore, paymentEventStore), provider, webhookSecret)
+	approvalThreshold, err := envInt64("ADJUSTMENT_APPROVAL_THRESHOLD_CENTS", 10000)
+	if err != nil {
+		logger.Error(ctx, "ADJUSTMENT_APPROVAL_THRESHOLD_CENTS is invalid", "error", err)
+		return nil, err
+	}
+	approvalWindowHours, err := envInt64("ADJUSTMENT_APPROVAL_WINDOW_HOURS", 24)
+	if err != nil {
+		logger.Error(ctx, "ADJUSTMENT_APPROVAL_WINDOW_HOURS is invalid", "error", err)
+		return nil, err
+	}
+	adjustmentApplier := adjustments.NewApplier(adjustmentStore, paymentStore, gateway)
+	adjustmentHandler := api.NewAdjustmentHandler(adjustmentStore, paymentStore, adjustmentApplier, provider, approvalThreshold, time.Duration(approvalWindowHours)*time.Hour)
+	promoHandler := api.NewPromoHandler(promoStore, creditStore, areaStore)
+
+	maintenanceMonitor := jobs.NewMaintenanceMonitor(maintenanceStore, time.Hour, store.DueThresholds{Days: 14, Miles: 500})
+	locationMaintainer := jobs.NewLocationMaintainer(locationStore, locationIndex, 6*time.Hour, 3)
+	idempotencySweeper := jobs.NewIdempotencySweeper(idempotencyStore, time.Hour)
+	adjustmentRecoverer := jobs.NewAdjustmentRecoverer(adjustmentStore, adjustmentApplier, 5*time.Minute, 10*time.Minute)
 
 	logger.Info(ctx, "application initialized successfully")
 
 	return &Application{
-		pool, signer, healthHandler, userHandler,
+		pool, signer, idempotencyStore, healthHandler, userHandler, driverHandler, documentHandler, vehicleHandler,
+		maintenanceHandler, scheduleHandler, locationHandler, areaHandler, taxHandler, paymentHandler,
+		webhookHandler, adjustmentHandler, promoHandler, maintenanceMonitor, locationMaintainer, idempotencySweeper,
+		adjustmentRecoverer,
 	}, nil
 
 }
+
+// envInt64 reads a non-negative integer setting, falling back to def when it is unset.
+func envInt64(key string, def int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// newBlobStore selects the document storage backend from BLOB_BACKEND ("fs" by default, or "s3").
+func newBlobStore() (blob.BlobStore, error) {
+	ctx := context.Background()
+	switch backend := os.Getenv("BLOB_BACKEND"); backend {
+	case "", "fs":
+		root := os.Getenv("BLOB_FS_ROOT")
+		if root == "" {
+			root = "./data/blobs"
+		}
+		logger.Info(ctx, "using filesystem blob storage", "root", root)
+		return blob.NewFSStore(root)
+	case "s3":
+		cfg := blob.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		logger.Info(ctx, "using s3 blob storage", "endpoint", cfg.Endpoint, "bucket", cfg.Bucket)
+		return blob.NewS3Store(cfg)
+	default:
+		return nil, fmt.Errorf("unknown BLOB_BACKEND %q", backend)
+	}
+}
+
+// newPaymentGateway selects the card processor from PAYMENT_GATEWAY ("stripe", or "fake" for local use)
+// and returns it with the provider name stored on saved cards.
+func newPaymentGateway() (payments.PaymentGateway, string, error) {
+	ctx := context.Background()
+	switch provider := os.Getenv("PAYMENT_GATEWAY"); provider {
+	case "":
+		// Never fall back to the fake: a deployment that forgot the variable would record payments
+		// that never happened.
+		return nil, "", errors.New("PAYMENT_GATEWAY must be set to stripe or fake")
+	case "fake":
+		logger.Warn(ctx, "using in-process fake payment gateway; no real charges will be made")
+		return payments.NewFakeGateway(), "fake", nil
+	case "stripe":
+		gw, err := payments.NewStripeGateway(payments.StripeConfig{
+			SecretKey: os.Getenv("STRIPE_SECRET_KEY"),
+			BaseURL:   os.Getenv("STRIPE_API_BASE"),
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		logger.Info(ctx, "using stripe payment gateway")
+		return gw, "stripe", nil
+	default:
+		return nil, "", fmt.Errorf("unknown PAYMENT_GATEWAY %q", provider)
+	}
+}
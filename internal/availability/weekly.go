@@ -0,0 +1,82 @@
+package availability
+
+import (
+	"sort"
+	"time"
+)
+
+// WeeklyWindow is a recurring slot on one weekday, expressed as offsets from local midnight.
+// An End at or before Start means the slot runs past midnight into the next day.
+type WeeklyWindow struct {
+	Weekday  time.Weekday
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+type interval struct{ start, end time.Time }
+
+// occurrences expands w into concrete intervals for every local date in [from, to], padded by a day
+// on each side so overnight slots that started the day before are included.
+func (w WeeklyWindow) occurrences(from, to time.Time) []interval {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	lf := from.In(loc)
+	day := time.Date(lf.Year(), lf.Month(), lf.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	last := to.In(loc).AddDate(0, 0, 1)
+
+	var out []interval
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != w.Weekday {
+			continue
+		}
+		// Build wall-clock times via time.Date so DST transitions land on the intended local time.
+		s := wallClock(day, w.Start, loc)
+		e := wallClock(day, w.End, loc)
+		if w.End <= w.Start {
+			e = wallClock(day.AddDate(0, 0, 1), w.End, loc)
+		}
+		out = append(out, interval{s, e})
+	}
+	return out
+}
+
+func wallClock(day time.Time, offset time.Duration, loc *time.Location) time.Time {
+	h := int(offset / time.Hour)
+	m := int((offset % time.Hour) / time.Minute)
+	s := int((offset % time.Minute) / time.Second)
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, s, 0, loc)
+}
+
+// Covers reports whether [start, end) lies entirely inside the union of the weekly windows.
+// Adjacent or overlapping slots (e.g. Mon 18:00-24:00 and Tue 00:00-02:00) are merged first.
+func Covers(windows []WeeklyWindow, start, end time.Time) bool {
+	if !end.After(start) {
+		return false
+	}
+	var all []interval
+	for _, w := range windows {
+		all = append(all, w.occurrences(start, end)...)
+	}
+	if len(all) == 0 {
+		return false
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+
+	cur := all[0]
+	for _, iv := range all[1:] {
+		if !iv.start.After(cur.end) {
+			if iv.end.After(cur.end) {
+				cur.end = iv.end
+			}
+			continue
+		}
+		if !start.Before(cur.start) && !end.After(cur.end) {
+			return true
+		}
+		cur = iv
+	}
+	return !start.Before(cur.start) && !end.After(cur.end)
+}
@@ -0,0 +1,70 @@
+package availability
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestCovers(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	// 2026-10-19 is a Monday; 2026-03-08 is the Sunday US clocks spring forward (02:00 -> 03:00).
+	at := func(loc *time.Location, month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, loc)
+	}
+	utc := func(day, hour, min int) time.Time { return at(time.UTC, time.October, day, hour, min) }
+	h := func(n float64) time.Duration { return time.Duration(n * float64(time.Hour)) }
+
+	overnight := []WeeklyWindow{{Weekday: time.Friday, Start: h(22), End: h(2)}}
+	adjacent := []WeeklyWindow{
+		{Weekday: time.Monday, Start: h(18), End: h(24)},
+		{Weekday: time.Tuesday, Start: 0, End: h(2)},
+	}
+	gap := []WeeklyWindow{
+		{Weekday: time.Monday, Start: h(18), End: h(22)},
+		{Weekday: time.Monday, Start: h(22.5), End: h(23)},
+	}
+	dst := []WeeklyWindow{
+		{Weekday: time.Sunday, Start: h(1), End: h(5), Location: ny},
+		{Weekday: time.Sunday, Start: h(8), End: h(12), Location: ny},
+	}
+
+	tests := []struct {
+		name       string
+		windows    []WeeklyWindow
+		start, end time.Time
+		want       bool
+	}{
+		{"no windows", nil, utc(19, 9, 0), utc(19, 10, 0), false},
+		{"empty range", adjacent, utc(19, 19, 0), utc(19, 19, 0), false},
+		{"inside one slot", adjacent, utc(19, 18, 0), utc(19, 24, 0), true},
+		{"before slot", adjacent, utc(19, 17, 30), utc(19, 19, 0), false},
+
+		{"overnight across midnight", overnight, utc(23, 23, 0), utc(24, 1, 30), true},
+		{"overnight after it ends", overnight, utc(24, 1, 30), utc(24, 2, 30), false},
+		{"overnight tail only", overnight, utc(24, 0, 30), utc(24, 1, 0), true},
+		{"overnight on the wrong night", overnight, utc(22, 23, 0), utc(23, 1, 0), false},
+
+		{"adjacent slots merge", adjacent, utc(19, 23, 0), utc(20, 1, 0), true},
+		{"adjacent slots full span", adjacent, utc(19, 18, 0), utc(20, 2, 0), true},
+		{"past the merged end", adjacent, utc(19, 23, 0), utc(20, 2, 1), false},
+		{"monday slot alone stops at midnight", adjacent[:1], utc(19, 23, 0), utc(20, 1, 0), false},
+		{"gap between slots", gap, utc(19, 21, 0), utc(19, 22, 45), false},
+
+		{"spring forward across the skipped hour", dst, at(ny, time.March, 8, 1, 30), at(ny, time.March, 8, 4, 30), true},
+		{"spring forward slot end is wall clock", dst, at(ny, time.March, 8, 4, 0), at(ny, time.March, 8, 5, 0), true},
+		{"spring forward morning slot starts at local 08:00", dst, at(ny, time.March, 8, 8, 0), at(ny, time.March, 8, 9, 0), true},
+		{"spring forward morning slot ends at local 12:00", dst, at(ny, time.March, 8, 11, 30), at(ny, time.March, 8, 12, 30), false},
+		{"spring forward in UTC terms", dst, time.Date(2026, time.March, 8, 12, 0, 0, 0, time.UTC), time.Date(2026, time.March, 8, 16, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Covers(tt.windows, tt.start, tt.end); got != tt.want {
+				t.Errorf("Covers(%s, %s) = %v, want %v", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
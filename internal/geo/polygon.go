@@ -0,0 +1,142 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidGeometry = errors.New("invalid geometry")
+
+// Point is a WGS84 coordinate. GeoJSON orders positions as [lng, lat].
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Ring is a closed linear ring of [lng, lat] positions; the first and last positions are equal.
+type Ring [][2]float64
+
+// Polygon is an outer ring followed by zero or more holes.
+type Polygon []Ring
+
+// MultiPolygon is the normalized form every service area is stored and tested in.
+type MultiPolygon []Polygon
+
+// BBox is the bounding box of a geometry, used as a cheap prefilter before the exact test.
+type BBox struct {
+	MinLat, MinLng, MaxLat, MaxLng float64
+}
+
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ParseGeometry accepts a GeoJSON Polygon or MultiPolygon geometry object and validates it.
+func ParseGeometry(raw []byte) (MultiPolygon, error) {
+	var g geometry
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+	var mp MultiPolygon
+	switch g.Type {
+	case "Polygon":
+		var p Polygon
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		mp = MultiPolygon{p}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &mp); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: type must be Polygon or MultiPolygon", ErrInvalidGeometry)
+	}
+	if err := mp.validate(); err != nil {
+		return nil, err
+	}
+	return mp, nil
+}
+
+func (mp MultiPolygon) validate() error {
+	if len(mp) == 0 {
+		return fmt.Errorf("%w: no polygons", ErrInvalidGeometry)
+	}
+	for _, poly := range mp {
+		if len(poly) == 0 {
+			return fmt.Errorf("%w: polygon has no rings", ErrInvalidGeometry)
+		}
+		for _, ring := range poly {
+			if len(ring) < 4 {
+				return fmt.Errorf("%w: ring needs at least 4 positions", ErrInvalidGeometry)
+			}
+			if ring[0] != ring[len(ring)-1] {
+				return fmt.Errorf("%w: ring is not closed", ErrInvalidGeometry)
+			}
+			for _, pos := range ring {
+				if pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+					return fmt.Errorf("%w: position out of range", ErrInvalidGeometry)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalGeoJSON renders the geometry as a GeoJSON MultiPolygon object.
+func (mp MultiPolygon) MarshalGeoJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"type": "MultiPolygon", "coordinates": mp})
+}
+
+func (mp MultiPolygon) BBox() BBox {
+	b := BBox{MinLat: 90, MinLng: 180, MaxLat: -90, MaxLng: -180}
+	for _, poly := range mp {
+		for _, pos := range poly[0] {
+			b.MinLng, b.MaxLng = min(b.MinLng, pos[0]), max(b.MaxLng, pos[0])
+			b.MinLat, b.MaxLat = min(b.MinLat, pos[1]), max(b.MaxLat, pos[1])
+		}
+	}
+	return b
+}
+
+// Contains reports whether p is inside any polygon and outside that polygon's holes.
+// A point exactly on an edge may fall either way, but one on an edge or corner shared by adjacent
+// polygons is inside exactly one of them, so zones that tile an area never both claim it.
+func (mp MultiPolygon) Contains(p Point) bool {
+	for _, poly := range mp {
+		if !ringContains(poly[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range poly[1:] {
+			if ringContains(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains is the even-odd ray casting test; service areas are metro-sized, so treating
+// lng/lat as planar is accurate enough and areas crossing the antimeridian are not supported.
+func ringContains(ring Ring, p Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > p.Lat) != (yj > p.Lat) && p.Lng < (xj-xi)*(p.Lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,123 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+)
+
+// square returns a closed ring for the axis-aligned square with corners (lng0, lat0) and (lng1, lat1).
+func square(lng0, lat0, lng1, lat1 float64) Ring {
+	return Ring{{lng0, lat0}, {lng1, lat0}, {lng1, lat1}, {lng0, lat1}, {lng0, lat0}}
+}
+
+func TestParseGeometry(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantErr  bool
+		polygons int
+	}{
+		{"polygon", `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]]]}`, false, 1},
+		{"polygon with hole", `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]}`, false, 1},
+		{"multipolygon", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[5,5],[6,5],[6,6],[5,5]]]]}`, false, 2},
+		{"unclosed ring", `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10]]]}`, true, 0},
+		{"unclosed hole", `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6]]]}`, true, 0},
+		{"too few positions", `{"type":"Polygon","coordinates":[[[0,0],[10,0],[0,0]]]}`, true, 0},
+		{"position out of range", `{"type":"Polygon","coordinates":[[[0,0],[181,0],[10,10],[0,0]]]}`, true, 0},
+		{"polygon without rings", `{"type":"Polygon","coordinates":[]}`, true, 0},
+		{"empty multipolygon", `{"type":"MultiPolygon","coordinates":[]}`, true, 0},
+		{"unsupported type", `{"type":"Point","coordinates":[0,0]}`, true, 0},
+		{"malformed coordinates", `{"type":"Polygon","coordinates":"nope"}`, true, 0},
+		{"not json", `{`, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp, err := ParseGeometry([]byte(tt.raw))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGeometry) {
+					t.Fatalf("err = %v, want ErrInvalidGeometry", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(mp) != tt.polygons {
+				t.Errorf("polygons = %d, want %d", len(mp), tt.polygons)
+			}
+		})
+	}
+}
+
+func TestMultiPolygonContains(t *testing.T) {
+	withHole := MultiPolygon{{square(0, 0, 10, 10), square(4, 4, 6, 6)}}
+	// A concave "U": the notch between the arms is outside.
+	u := MultiPolygon{{Ring{{0, 0}, {9, 0}, {9, 9}, {6, 9}, {6, 3}, {3, 3}, {3, 9}, {0, 9}, {0, 0}}}}
+	islands := MultiPolygon{{square(0, 0, 1, 1)}, {square(5, 5, 6, 6)}}
+
+	tests := []struct {
+		name string
+		mp   MultiPolygon
+		p    Point
+		want bool
+	}{
+		{"inside", withHole, Point{Lat: 2, Lng: 2}, true},
+		{"outside", withHole, Point{Lat: 12, Lng: 2}, false},
+		{"in hole", withHole, Point{Lat: 5, Lng: 5}, false},
+		{"between hole and edge", withHole, Point{Lat: 5, Lng: 8}, true},
+		{"ray passes through hole", withHole, Point{Lat: 5, Lng: 2}, true},
+		{"concave arm", u, Point{Lat: 6, Lng: 1.5}, true},
+		{"concave notch", u, Point{Lat: 6, Lng: 4.5}, false},
+		{"ray through vertex", u, Point{Lat: 3, Lng: 1}, true},
+		{"second polygon", islands, Point{Lat: 5.5, Lng: 5.5}, true},
+		{"between polygons", islands, Point{Lat: 3, Lng: 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mp.Contains(tt.p); got != tt.want {
+				t.Errorf("Contains(%+v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+// Zones tile the map, so a point on a shared edge or corner must land in exactly one of them;
+// otherwise a pickup on a boundary would price in two zones or in none.
+func TestContainsBoundaryBelongsToOneTile(t *testing.T) {
+	tiles := []MultiPolygon{
+		{{square(0, 0, 10, 10)}},
+		{{square(10, 0, 20, 10)}},
+		{{square(0, 10, 10, 20)}},
+		{{square(10, 10, 20, 20)}},
+	}
+	points := []Point{
+		{Lat: 5, Lng: 10},  // vertical shared edge
+		{Lat: 10, Lng: 5},  // horizontal shared edge
+		{Lat: 10, Lng: 10}, // corner shared by all four
+		{Lat: 10, Lng: 15}, // horizontal shared edge, right half
+		{Lat: 15, Lng: 10}, // vertical shared edge, top half
+		{Lat: 0, Lng: 10},  // shared vertex on the outer boundary
+	}
+	for _, p := range points {
+		n := 0
+		for _, tile := range tiles {
+			if tile.Contains(p) {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("point %+v is in %d tiles, want 1", p, n)
+		}
+	}
+}
+
+func TestBBox(t *testing.T) {
+	mp := MultiPolygon{{square(-1, 2, 3, 4), square(0, 2.5, 1, 3)}, {square(5, -6, 7, -5)}}
+	want := BBox{MinLat: -6, MinLng: -1, MaxLat: 4, MaxLng: 7}
+	if got := mp.BBox(); got != want {
+		t.Errorf("BBox = %+v, want %+v", got, want)
+	}
+	if !want.Contains(Point{Lat: 4, Lng: 7}) || want.Contains(Point{Lat: 4.1, Lng: 0}) {
+		t.Error("BBox.Contains should include its edges and nothing beyond")
+	}
+}
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const maxJSONBodyBytes = 1 << 20
+
+// DecodeJSON reads a size-limited JSON body into dst, rejecting unknown fields.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	defer r.Body.Close()
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(dst)
+}
+
+func URLParamUUID(r *http.Request, key string) (uuid.UUID, error) {
+	return uuid.Parse(chi.URLParam(r, key))
+}
+
+// QueryInt returns the named query parameter clamped to [min, max], or def when absent or malformed.
+func QueryInt(r *http.Request, key string, def, min, max int) int {
+	v, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil {
+		return def
+	}
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,47 @@
+package helper
+
+import "strings"
+
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+func vinValue(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'H':
+		return int(c-'A') + 1, true
+	case c >= 'J' && c <= 'N':
+		return int(c-'J') + 1, true
+	case c == 'P':
+		return 7, true
+	case c == 'R':
+		return 9, true
+	case c >= 'S' && c <= 'Z':
+		return int(c-'S') + 2, true
+	}
+	// I, O and Q are never valid in a VIN.
+	return 0, false
+}
+
+func NormalizeVIN(vin string) string { return strings.ToUpper(strings.TrimSpace(vin)) }
+
+// ValidVIN checks length, alphabet and the North American check digit in position 9.
+func ValidVIN(vin string) bool {
+	vin = NormalizeVIN(vin)
+	if len(vin) != 17 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		v, ok := vinValue(vin[i])
+		if !ok {
+			return false
+		}
+		sum += v * vinWeights[i]
+	}
+	check := byte('0' + sum%11)
+	if sum%11 == 10 {
+		check = 'X'
+	}
+	return vin[8] == check
+}
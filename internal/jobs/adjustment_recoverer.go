@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/adjustments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+// AdjustmentRecoverer settles payment adjustments left in processing, e.g. because the process died or
+// the request timed out between recording the adjustment and hearing back from the gateway. The
+// gateway call is retried with the adjustment's original idempotency key, so money only moves once.
+type AdjustmentRecoverer struct {
+	store      store.AdjustmentStore
+	applier    *adjustments.Applier
+	interval   time.Duration
+	staleAfter time.Duration
+}
+
+func NewAdjustmentRecoverer(as store.AdjustmentStore, ap *adjustments.Applier, interval, staleAfter time.Duration) *AdjustmentRecoverer {
+	return &AdjustmentRecoverer{store: as, applier: ap, interval: interval, staleAfter: staleAfter}
+}
+
+// Run works once immediately and then on every tick until ctx is cancelled.
+func (r *AdjustmentRecoverer) Run(ctx context.Context) {
+	logger.Info(ctx, "adjustment recoverer started", "interval", r.interval.String(), "stale_after", r.staleAfter.String())
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.tick(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "adjustment recoverer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *AdjustmentRecoverer) tick(ctx context.Context) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	stuck, err := r.store.ListStuck(ctxTimeout, time.Now().Add(-r.staleAfter), 50)
+	if err != nil {
+		logger.Error(ctx, "failed to list stuck payment adjustments", "error", err)
+		return
+	}
+	settled := 0
+	for i := range stuck {
+		a := &stuck[i]
+		out, err := r.applier.Apply(ctxTimeout, a)
+		if err != nil {
+			if !errors.Is(err, store.ErrAdjustmentNotPending) {
+				logger.Error(ctx, "failed to recover payment adjustment", "adjustment_id", a.ID, "error", err)
+			}
+			continue
+		}
+		if out.Status != store.AdjustmentProcessing {
+			settled++
+			logger.Info(ctx, "recovered payment adjustment", "adjustment_id", a.ID, "status", out.Status)
+			logger.Audit(ctx, logger.AuditPaymentAdjustmentApply, nil, "", "", out.Status != store.AdjustmentFailed, map[string]any{
+				"actor":             "system:adjustment_recoverer",
+				"adjustment_id":     out.ID,
+				"payment_intent_id": out.PaymentIntentID,
+				"kind":              out.Kind,
+				"reason":            out.Reason,
+				"amount_cents":      out.AmountCents,
+				"status":            out.Status,
+			})
+		}
+	}
+	logger.Debug(ctx, "adjustment recovery completed", "stuck", len(stuck), "settled", settled)
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+// IdempotencySweeper deletes stored Idempotency-Key responses once they expire.
+type IdempotencySweeper struct {
+	store    store.IdempotencyStore
+	interval time.Duration
+}
+
+func NewIdempotencySweeper(is store.IdempotencyStore, interval time.Duration) *IdempotencySweeper {
+	return &IdempotencySweeper{store: is, interval: interval}
+}
+
+// Run works once immediately and then on every tick until ctx is cancelled.
+func (s *IdempotencySweeper) Run(ctx context.Context) {
+	logger.Info(ctx, "idempotency sweeper started", "interval", s.interval.String())
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "idempotency sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *IdempotencySweeper) tick(ctx context.Context) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	n, err := s.store.DeleteExpired(ctxTimeout, time.Now())
+	if err != nil {
+		logger.Error(ctx, "failed to delete expired idempotency keys", "error", err)
+		return
+	}
+	logger.Debug(ctx, "idempotency sweep completed", "deleted", n)
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/diagnosis/luxsuv-api-v2/internal/tracking"
+)
+
+// LocationMaintainer keeps breadcrumb partitions created ahead of time and prunes stale
+// entries from the in-memory position index.
+type LocationMaintainer struct {
+	store    store.LocationStore
+	index    *tracking.Index
+	interval time.Duration
+	ahead    int
+}
+
+func NewLocationMaintainer(ls store.LocationStore, idx *tracking.Index, interval time.Duration, monthsAhead int) *LocationMaintainer {
+	return &LocationMaintainer{store: ls, index: idx, interval: interval, ahead: monthsAhead}
+}
+
+// Run works once immediately and then on every tick until ctx is cancelled.
+func (m *LocationMaintainer) Run(ctx context.Context) {
+	logger.Info(ctx, "location maintainer started", "interval", m.interval.String())
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.tick(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "location maintainer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *LocationMaintainer) tick(ctx context.Context) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	// Include the current month so a fresh database is covered even if the migration ran long ago.
+	if err := m.store.EnsurePartitions(ctxTimeout, now, m.ahead+1); err != nil {
+		logger.Error(ctx, "failed to ensure location partitions", "error", err)
+	}
+	// Anything here means pings arrived for a month with no partition; the next EnsurePartitions for that
+	// month moves them, but rows outside the maintained window stay until someone looks.
+	if n, err := m.store.DefaultPartitionRows(ctxTimeout); err != nil {
+		logger.Error(ctx, "failed to count default partition rows", "error", err)
+	} else if n > 0 {
+		logger.Warn(ctx, "location pings in default partition", "rows", n)
+	}
+	pruned := m.index.Prune(now)
+	logger.Debug(ctx, "location maintenance completed", "pruned", pruned)
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+// MaintenanceMonitor periodically recomputes which maintenance schedules are due or overdue. It only
+// logs a schedule when its state changes, not on every tick it stays flagged.
+type MaintenanceMonitor struct {
+	store      store.MaintenanceStore
+	interval   time.Duration
+	thresholds store.DueThresholds
+}
+
+func NewMaintenanceMonitor(ms store.MaintenanceStore, interval time.Duration, th store.DueThresholds) *MaintenanceMonitor {
+	return &MaintenanceMonitor{store: ms, interval: interval, thresholds: th}
+}
+
+// Run checks once immediately and then on every tick until ctx is cancelled.
+func (m *MaintenanceMonitor) Run(ctx context.Context) {
+	logger.Info(ctx, "maintenance monitor started", "interval", m.interval.String())
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "maintenance monitor stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *MaintenanceMonitor) check(ctx context.Context) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	flagged, err := m.store.RefreshDueStates(ctxTimeout, time.Now().UTC(), m.thresholds)
+	if err != nil {
+		logger.Error(ctx, "maintenance check failed", "error", err)
+		return
+	}
+	for _, sch := range flagged {
+		logger.Warn(ctx, "vehicle maintenance "+sch.DueState,
+			"vehicle_id", sch.VehicleID,
+			"schedule_id", sch.ID,
+			"kind", sch.Kind,
+		)
+	}
+	logger.Debug(ctx, "maintenance check completed", "flagged", len(flagged))
+}
@@ -21,6 +21,40 @@ const (
 	AuditTokenRevoke       AuditEvent = "TOKEN_REVOKE"
 	AuditAccountActivate   AuditEvent = "ACCOUNT_ACTIVATE"
 	AuditAccountDeactivate AuditEvent = "ACCOUNT_DEACTIVATE"
+
+	AuditDriverApplicationSubmit AuditEvent = "DRIVER_APPLICATION_SUBMIT"
+	AuditDriverBackgroundCheck   AuditEvent = "DRIVER_BACKGROUND_CHECK"
+	AuditDriverApprove           AuditEvent = "DRIVER_APPROVE"
+	AuditDriverReject            AuditEvent = "DRIVER_REJECT"
+	AuditDriverDocumentUpload    AuditEvent = "DRIVER_DOCUMENT_UPLOAD"
+	AuditDriverAvailability      AuditEvent = "DRIVER_AVAILABILITY"
+	AuditDriverTimeOff           AuditEvent = "DRIVER_TIME_OFF"
+	AuditDriverDuty              AuditEvent = "DRIVER_DUTY"
+	AuditDriverShift             AuditEvent = "DRIVER_SHIFT"
+
+	AuditVehicleCreate   AuditEvent = "VEHICLE_CREATE"
+	AuditVehicleUpdate   AuditEvent = "VEHICLE_UPDATE"
+	AuditVehicleRetire   AuditEvent = "VEHICLE_RETIRE"
+	AuditVehicleAssign   AuditEvent = "VEHICLE_ASSIGN"
+	AuditVehicleUnassign AuditEvent = "VEHICLE_UNASSIGN"
+
+	AuditMaintenanceSchedule AuditEvent = "MAINTENANCE_SCHEDULE"
+	AuditMaintenanceRecord   AuditEvent = "MAINTENANCE_RECORD"
+
+	AuditServiceAreaImport AuditEvent = "SERVICE_AREA_IMPORT"
+	AuditServiceAreaDelete AuditEvent = "SERVICE_AREA_DELETE"
+	AuditZoneRate          AuditEvent = "ZONE_RATE"
+	AuditTaxRule           AuditEvent = "TAX_RULE"
+
+	AuditPaymentMethodAdd         AuditEvent = "PAYMENT_METHOD_ADD"
+	AuditPaymentMethodRemove      AuditEvent = "PAYMENT_METHOD_REMOVE"
+	AuditPaymentAdjustmentCreate  AuditEvent = "PAYMENT_ADJUSTMENT_CREATE"
+	AuditPaymentAdjustmentApprove AuditEvent = "PAYMENT_ADJUSTMENT_APPROVE"
+	AuditPaymentAdjustmentReject  AuditEvent = "PAYMENT_ADJUSTMENT_REJECT"
+	AuditPaymentAdjustmentApply   AuditEvent = "PAYMENT_ADJUSTMENT_APPLY"
+
+	AuditPromoCode    AuditEvent = "PROMO_CODE"
+	AuditCreditAdjust AuditEvent = "CREDIT_ADJUST"
 )
 
 var auditLogger *slog.Logger
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/apperror"
+	"github.com/diagnosis/luxsuv-api-v2/internal/helper"
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLen     = 255
+	idempotencyTTL           = 24 * time.Hour
+	idempotencyStaleAfter    = time.Minute
+
+	// idempotencyMaxRequestBody matches the largest JSON route (the GeoJSON area import), since the
+	// body is buffered in memory before the handler's own limit applies.
+	idempotencyMaxRequestBody = 5 << 20
+)
+
+// Idempotency replays the stored response when a POST or PATCH is retried with the same
+// Idempotency-Key. Keys are scoped per user, so it must be mounted after RequireJWT; requests
+// without the header (or without a user) pass straight through. Reusing a key with a different
+// method, path or body is rejected with 409, as is a retry while the first attempt is still running.
+// Server errors are not stored, so the client may retry them under the same key. Multipart uploads
+// are not buffered and pass straight through.
+func Idempotency(is store.IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) ||
+				strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			userID, ok := GetUserID(ctx)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotencyKeyMaxLen || strings.TrimSpace(key) != key {
+				helper.RespondError(w, r, apperror.BadRequest("Idempotency-Key must be 1-255 characters without surrounding whitespace"))
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, idempotencyMaxRequestBody+1))
+			if err != nil {
+				helper.RespondError(w, r, apperror.BadRequest("Failed to read request body"))
+				return
+			}
+			if len(body) > idempotencyMaxRequestBody {
+				helper.RespondError(w, r, apperror.New(apperror.CodeBadRequest, "Request body too large", http.StatusRequestEntityTooLarge))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.New()
+			sum.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+			sum.Write(body)
+			hash := hex.EncodeToString(sum.Sum(nil))
+
+			ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+			existing, claimed, err := is.Begin(ctxTimeout, &store.IdempotencyRecord{
+				UserID:      userID,
+				Key:         key,
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				RequestHash: hash,
+				ExpiresAt:   time.Now().Add(idempotencyTTL),
+			}, idempotencyStaleAfter)
+			cancel()
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					helper.RespondError(w, r, apperror.Conflict("A request with this Idempotency-Key is still in progress"))
+					return
+				}
+				helper.RespondError(w, r, apperror.InternalError("Failed to process Idempotency-Key", err))
+				logger.Error(ctx, "failed to claim idempotency key", "error", err)
+				return
+			}
+
+			if !claimed {
+				switch {
+				case existing.RequestHash != hash:
+					logger.Warn(ctx, "idempotency key reused with different request", "user_id", userID, "path", r.URL.Path)
+					helper.RespondError(w, r, apperror.Conflict("Idempotency-Key was already used for a different request"))
+				case !existing.CompletedAt.Valid || existing.ResponseStatus == nil:
+					helper.RespondError(w, r, apperror.Conflict("A request with this Idempotency-Key is still in progress"))
+				default:
+					if existing.ContentType.Valid {
+						w.Header().Set("Content-Type", existing.ContentType.String)
+					}
+					w.Header().Set(IdempotentReplayedHeader, "true")
+					w.WriteHeader(int(*existing.ResponseStatus))
+					_, _ = w.Write(existing.ResponseBody)
+				}
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				// Runs on panic too, so an aborted request doesn't hold the key until it goes stale.
+				finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+				if !completed || rec.status >= http.StatusInternalServerError {
+					if err := is.Release(finishCtx, userID, key); err != nil {
+						logger.Error(ctx, "failed to release idempotency key", "error", err)
+					}
+					return
+				}
+				if err := is.Complete(finishCtx, userID, key, rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+					logger.Error(ctx, "failed to store idempotent response", "error", err)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+			completed = true
+		})
+	}
+}
+
+// responseRecorder passes the response through to the client while keeping a copy for replay.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.wroteHeader {
+		return
+	}
+	rr.status = status
+	rr.wroteHeader = true
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,195 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeGateway is an in-process gateway for development and tests. Tokens behave like provider
+// test cards: anything containing "declined" saves fine but fails authorization, "invalid" is
+// rejected at save time, and everything else succeeds.
+type FakeGateway struct {
+	mu        sync.Mutex
+	seq       int
+	customers map[string]bool
+	cards     map[string]Card
+	charges   map[string]*Charge
+	refunded  map[string]int64
+	declines  map[string]bool
+	// replies caches results by idempotency key so retries return the original outcome.
+	replies map[string]any
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		customers: make(map[string]bool),
+		cards:     make(map[string]Card),
+		charges:   make(map[string]*Charge),
+		refunded:  make(map[string]int64),
+		declines:  make(map[string]bool),
+		replies:   make(map[string]any),
+	}
+}
+
+func (g *FakeGateway) nextID(prefix string) string {
+	g.seq++
+	return fmt.Sprintf("%s_fake_%06d", prefix, g.seq)
+}
+
+func (g *FakeGateway) SaveCard(ctx context.Context, req SaveCardRequest) (*Card, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if req.Token == "" || strings.Contains(req.Token, "invalid") {
+		return nil, ErrInvalidRequest
+	}
+	customerID := req.CustomerID
+	if customerID == "" {
+		customerID = g.nextID("cus")
+		g.customers[customerID] = true
+	} else if !g.customers[customerID] {
+		return nil, ErrNotFound
+	}
+	last4 := "4242"
+	if len(req.Token) >= 4 && strings.Trim(req.Token[len(req.Token)-4:], "0123456789") == "" {
+		last4 = req.Token[len(req.Token)-4:]
+	}
+	card := Card{
+		CustomerID: customerID,
+		MethodID:   g.nextID("pm"),
+		Brand:      "visa",
+		Last4:      last4,
+		ExpMonth:   12,
+		ExpYear:    2030,
+	}
+	g.cards[card.MethodID] = card
+	g.declines[card.MethodID] = strings.Contains(req.Token, "declined")
+	return &card, nil
+}
+
+// replay returns the cached result for key, if any, and whether one was found.
+func replay[T any](g *FakeGateway, key string) (*T, bool, error) {
+	if key == "" {
+		return nil, false, nil
+	}
+	v, ok := g.replies[key]
+	if !ok {
+		return nil, false, nil
+	}
+	switch r := v.(type) {
+	case error:
+		return nil, true, r
+	case T:
+		return &r, true, nil
+	}
+	return nil, true, ErrInvalidRequest
+}
+
+func (g *FakeGateway) remember(key string, v any) {
+	if key != "" {
+		g.replies[key] = v
+	}
+}
+
+func (g *FakeGateway) Authorize(ctx context.Context, req AuthorizeRequest) (*Charge, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok, err := replay[Charge](g, req.IdempotencyKey); ok {
+		return c, err
+	}
+	if req.AmountCents <= 0 || req.Currency == "" {
+		return nil, ErrInvalidRequest
+	}
+	card, ok := g.cards[req.MethodID]
+	if !ok || card.CustomerID != req.CustomerID {
+		return nil, ErrNotFound
+	}
+	if g.declines[req.MethodID] {
+		g.remember(req.IdempotencyKey, ErrCardDeclined)
+		return nil, ErrCardDeclined
+	}
+	c := &Charge{ID: g.nextID("pi"), Status: StatusRequiresCapture, AmountCents: req.AmountCents, Currency: req.Currency}
+	g.charges[c.ID] = c
+	g.remember(req.IdempotencyKey, *c)
+	out := *c
+	return &out, nil
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, chargeID string, amountCents int64, idempotencyKey string) (*Charge, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok, err := replay[Charge](g, idempotencyKey); ok {
+		return c, err
+	}
+	c, ok := g.charges[chargeID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if c.Status != StatusRequiresCapture {
+		return nil, ErrInvalidState
+	}
+	if amountCents == 0 {
+		amountCents = c.AmountCents
+	}
+	if amountCents < 0 || amountCents > c.AmountCents {
+		return nil, ErrInvalidRequest
+	}
+	c.Status = StatusSucceeded
+	c.AmountCapturedCents = amountCents
+	g.remember(idempotencyKey, *c)
+	out := *c
+	return &out, nil
+}
+
+func (g *FakeGateway) Void(ctx context.Context, chargeID string, idempotencyKey string) (*Charge, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok, err := replay[Charge](g, idempotencyKey); ok {
+		return c, err
+	}
+	c, ok := g.charges[chargeID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if c.Status != StatusRequiresCapture {
+		return nil, ErrInvalidState
+	}
+	c.Status = StatusCanceled
+	g.remember(idempotencyKey, *c)
+	out := *c
+	return &out, nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, chargeID string, amountCents int64, idempotencyKey string) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r, ok, err := replay[Refund](g, idempotencyKey); ok {
+		return r, err
+	}
+	c, ok := g.charges[chargeID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if c.Status != StatusSucceeded {
+		return nil, ErrInvalidState
+	}
+	remaining := c.AmountCapturedCents - g.refunded[chargeID]
+	if amountCents == 0 {
+		amountCents = remaining
+	}
+	if amountCents <= 0 || amountCents > remaining {
+		return nil, ErrInvalidRequest
+	}
+	g.refunded[chargeID] += amountCents
+	r := Refund{ID: g.nextID("re"), ChargeID: chargeID, AmountCents: amountCents, Status: StatusSucceeded}
+	g.remember(idempotencyKey, r)
+	return &r, nil
+}
+
+var _ PaymentGateway = (*FakeGateway)(nil)
@@ -0,0 +1,215 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func fakeWithCard(t *testing.T, token string) (*FakeGateway, *Card) {
+	t.Helper()
+	g := NewFakeGateway()
+	card, err := g.SaveCard(context.Background(), SaveCardRequest{Email: "rider@example.com", Token: token})
+	if err != nil {
+		t.Fatalf("save card: %v", err)
+	}
+	return g, card
+}
+
+func authorize(t *testing.T, g *FakeGateway, card *Card, amount int64, key string) *Charge {
+	t.Helper()
+	c, err := g.Authorize(context.Background(), AuthorizeRequest{
+		CustomerID: card.CustomerID, MethodID: card.MethodID, AmountCents: amount, Currency: "usd", IdempotencyKey: key,
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	return c
+}
+
+func TestFakeGatewaySaveCard(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		req       SaveCardRequest
+		wantErr   error
+		wantLast4 string
+	}{
+		{"new customer", SaveCardRequest{Token: "tok_visa"}, nil, "4242"},
+		{"last4 from token", SaveCardRequest{Token: "tok_card_1881"}, nil, "1881"},
+		{"empty token", SaveCardRequest{}, ErrInvalidRequest, ""},
+		{"invalid token", SaveCardRequest{Token: "tok_invalid"}, ErrInvalidRequest, ""},
+		{"unknown customer", SaveCardRequest{CustomerID: "cus_nope", Token: "tok_visa"}, ErrNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := NewFakeGateway().SaveCard(ctx, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && card.Last4 != tt.wantLast4 {
+				t.Errorf("last4 = %s, want %s", card.Last4, tt.wantLast4)
+			}
+		})
+	}
+
+	g, card := fakeWithCard(t, "tok_visa")
+	second, err := g.SaveCard(ctx, SaveCardRequest{CustomerID: card.CustomerID, Token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("save second card: %v", err)
+	}
+	if second.CustomerID != card.CustomerID || second.MethodID == card.MethodID {
+		t.Errorf("second card = %+v, want same customer and a new method", second)
+	}
+}
+
+func TestFakeGatewayLifecycle(t *testing.T) {
+	type step struct {
+		op         string // capture, void, refund
+		amount     int64
+		wantErr    error
+		wantStatus string // charge status after a successful capture or void
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"full capture", []step{{"capture", 0, nil, StatusSucceeded}}},
+		{"partial capture", []step{{"capture", 300, nil, StatusSucceeded}}},
+		{"capture over hold", []step{{"capture", 1001, ErrInvalidRequest, ""}}},
+		{"capture twice", []step{{"capture", 0, nil, StatusSucceeded}, {"capture", 0, ErrInvalidState, ""}}},
+		{"void then capture", []step{{"void", 0, nil, StatusCanceled}, {"capture", 0, ErrInvalidState, ""}}},
+		{"capture then void", []step{{"capture", 0, nil, StatusSucceeded}, {"void", 0, ErrInvalidState, ""}}},
+		{"refund before capture", []step{{"refund", 100, ErrInvalidState, ""}}},
+		{"refund everything", []step{{"capture", 0, nil, StatusSucceeded}, {"refund", 0, nil, ""}, {"refund", 0, ErrInvalidRequest, ""}}},
+		{"partial refunds", []step{
+			{"capture", 0, nil, StatusSucceeded},
+			{"refund", 400, nil, ""},
+			{"refund", 600, nil, ""},
+			{"refund", 1, ErrInvalidRequest, ""},
+		}},
+		{"refund over captured", []step{{"capture", 300, nil, StatusSucceeded}, {"refund", 301, ErrInvalidRequest, ""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g, card := fakeWithCard(t, "tok_visa")
+			c := authorize(t, g, card, 1000, "auth")
+			if c.Status != StatusRequiresCapture {
+				t.Fatalf("authorized status = %s", c.Status)
+			}
+			for i, s := range tt.steps {
+				key := fmt.Sprintf("step-%d", i)
+				var (
+					got *Charge
+					err error
+				)
+				switch s.op {
+				case "capture":
+					got, err = g.Capture(ctx, c.ID, s.amount, key)
+				case "void":
+					got, err = g.Void(ctx, c.ID, key)
+				case "refund":
+					_, err = g.Refund(ctx, c.ID, s.amount, key)
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d %s: err = %v, want %v", i, s.op, err, s.wantErr)
+				}
+				if got != nil && s.wantStatus != "" && got.Status != s.wantStatus {
+					t.Errorf("step %d %s: status = %s, want %s", i, s.op, got.Status, s.wantStatus)
+				}
+				if s.op == "capture" && err == nil {
+					want := s.amount
+					if want == 0 {
+						want = c.AmountCents
+					}
+					if got.AmountCapturedCents != want {
+						t.Errorf("step %d: captured = %d, want %d", i, got.AmountCapturedCents, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFakeGatewayUnknownCharge(t *testing.T) {
+	ctx := context.Background()
+	g := NewFakeGateway()
+	if _, err := g.Capture(ctx, "pi_missing", 0, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("capture err = %v, want ErrNotFound", err)
+	}
+	if _, err := g.Void(ctx, "pi_missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("void err = %v, want ErrNotFound", err)
+	}
+	if _, err := g.Refund(ctx, "pi_missing", 0, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refund err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFakeGatewayIdempotentReplay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("authorize", func(t *testing.T) {
+		g, card := fakeWithCard(t, "tok_visa")
+		first := authorize(t, g, card, 1000, "key-1")
+		again := authorize(t, g, card, 1000, "key-1")
+		if again.ID != first.ID {
+			t.Errorf("replayed charge = %s, want %s", again.ID, first.ID)
+		}
+		if other := authorize(t, g, card, 1000, "key-2"); other.ID == first.ID {
+			t.Error("a different key returned the same charge")
+		}
+		if len(g.charges) != 2 {
+			t.Errorf("charges = %d, want 2", len(g.charges))
+		}
+	})
+
+	t.Run("declined authorize", func(t *testing.T) {
+		g, card := fakeWithCard(t, "tok_declined")
+		req := AuthorizeRequest{CustomerID: card.CustomerID, MethodID: card.MethodID, AmountCents: 1000, Currency: "usd", IdempotencyKey: "key-1"}
+		for i := range 2 {
+			if _, err := g.Authorize(ctx, req); !errors.Is(err, ErrCardDeclined) {
+				t.Fatalf("attempt %d: err = %v, want ErrCardDeclined", i, err)
+			}
+		}
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		g, card := fakeWithCard(t, "tok_visa")
+		c := authorize(t, g, card, 1000, "auth")
+		first, err := g.Capture(ctx, c.ID, 0, "cap")
+		if err != nil {
+			t.Fatalf("capture: %v", err)
+		}
+		// A retry after the capture went through must return the original result, not ErrInvalidState.
+		again, err := g.Capture(ctx, c.ID, 0, "cap")
+		if err != nil || *again != *first {
+			t.Errorf("replayed capture = %+v, %v; want %+v", again, err, first)
+		}
+		if _, err := g.Capture(ctx, c.ID, 0, "cap-2"); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("new capture err = %v, want ErrInvalidState", err)
+		}
+	})
+
+	t.Run("refund", func(t *testing.T) {
+		g, card := fakeWithCard(t, "tok_visa")
+		c := authorize(t, g, card, 1000, "auth")
+		if _, err := g.Capture(ctx, c.ID, 0, "cap"); err != nil {
+			t.Fatalf("capture: %v", err)
+		}
+		first, err := g.Refund(ctx, c.ID, 600, "ref")
+		if err != nil {
+			t.Fatalf("refund: %v", err)
+		}
+		again, err := g.Refund(ctx, c.ID, 600, "ref")
+		if err != nil || again.ID != first.ID {
+			t.Errorf("replayed refund = %+v, %v; want %s", again, err, first.ID)
+		}
+		// The replay must not have refunded twice: 400 is still refundable.
+		rest, err := g.Refund(ctx, c.ID, 0, "ref-rest")
+		if err != nil || rest.AmountCents != 400 {
+			t.Errorf("remaining refund = %+v, %v; want 400", rest, err)
+		}
+	})
+}
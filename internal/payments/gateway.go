@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrCardDeclined   = errors.New("card declined")
+	ErrInvalidRequest = errors.New("invalid payment request")
+	ErrNotFound       = errors.New("payment object not found")
+	// ErrInvalidState is returned when an operation doesn't fit the charge's status, e.g. capturing a voided hold.
+	ErrInvalidState = errors.New("payment is not in a valid state for this operation")
+)
+
+// Charge statuses, mirroring the subset of the provider lifecycle we use.
+const (
+	StatusRequiresCapture = "requires_capture"
+	StatusSucceeded       = "succeeded"
+	StatusCanceled        = "canceled"
+)
+
+// Card is a tokenized card saved with the provider; raw card data never reaches this service.
+type Card struct {
+	CustomerID string
+	MethodID   string
+	Brand      string
+	Last4      string
+	ExpMonth   int
+	ExpYear    int
+}
+
+type SaveCardRequest struct {
+	// CustomerID is the provider customer to attach to; empty creates a new customer.
+	CustomerID string
+	Email      string
+	// Token is the client-side tokenized card (a provider payment method id).
+	Token string
+}
+
+type AuthorizeRequest struct {
+	CustomerID  string
+	MethodID    string
+	AmountCents int64
+	Currency    string
+	Description string
+	// IdempotencyKey makes retries of the same authorization return the original hold.
+	IdempotencyKey string
+}
+
+// Charge is a payment intent on the provider side.
+type Charge struct {
+	ID                  string
+	Status              string
+	AmountCents         int64
+	AmountCapturedCents int64
+	Currency            string
+}
+
+type Refund struct {
+	ID          string
+	ChargeID    string
+	AmountCents int64
+	Status      string
+}
+
+// PaymentGateway is the provider-agnostic card payment API. Amounts are in the currency's minor unit.
+// Every mutating call takes an idempotency key so callers can retry safely after timeouts.
+type PaymentGateway interface {
+	SaveCard(ctx context.Context, req SaveCardRequest) (*Card, error)
+	// Authorize places a hold for the amount without capturing it.
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Charge, error)
+	// Capture settles up to the authorized amount; zero captures the full hold.
+	Capture(ctx context.Context, chargeID string, amountCents int64, idempotencyKey string) (*Charge, error)
+	// Void releases an uncaptured hold.
+	Void(ctx context.Context, chargeID string, idempotencyKey string) (*Charge, error)
+	// Refund returns up to the captured amount; zero refunds whatever remains.
+	Refund(ctx context.Context, chargeID string, amountCents int64, idempotencyKey string) (*Refund, error)
+}
@@ -0,0 +1,227 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StripeConfig struct {
+	SecretKey string
+	// BaseURL defaults to the live API; point it at stripe-mock or another compatible server for dev.
+	BaseURL string
+}
+
+// StripeGateway implements PaymentGateway against the Stripe REST API (or any server speaking it)
+// using manual-capture PaymentIntents.
+type StripeGateway struct {
+	cfg    StripeConfig
+	client *http.Client
+}
+
+func NewStripeGateway(cfg StripeConfig) (*StripeGateway, error) {
+	if cfg.SecretKey == "" {
+		return nil, errors.New("stripe secret key is required")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.stripe.com"
+	}
+	if _, err := url.ParseRequestURI(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("invalid stripe base url %q", cfg.BaseURL)
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &StripeGateway{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+type stripeCard struct {
+	Brand    string `json:"brand"`
+	Last4    string `json:"last4"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+}
+
+type stripePaymentMethod struct {
+	ID       string     `json:"id"`
+	Customer string     `json:"customer"`
+	Card     stripeCard `json:"card"`
+}
+
+type stripeIntent struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Amount         int64  `json:"amount"`
+	AmountReceived int64  `json:"amount_received"`
+	Currency       string `json:"currency"`
+}
+
+func (pi *stripeIntent) charge() *Charge {
+	return &Charge{
+		ID:                  pi.ID,
+		Status:              pi.Status,
+		AmountCents:         pi.Amount,
+		AmountCapturedCents: pi.AmountReceived,
+		Currency:            pi.Currency,
+	}
+}
+
+type stripeRefund struct {
+	ID            string `json:"id"`
+	PaymentIntent string `json:"payment_intent"`
+	Amount        int64  `json:"amount"`
+	Status        string `json:"status"`
+}
+
+type stripeErrorBody struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// post sends a form-encoded request and decodes a 2xx body into out, mapping API errors to ours.
+func (g *StripeGateway) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.cfg.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.cfg.SecretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return stripeError(resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func stripeError(status int, body []byte) error {
+	var eb stripeErrorBody
+	_ = json.Unmarshal(body, &eb)
+	e := eb.Error
+	switch {
+	case e.Type == "card_error" || status == http.StatusPaymentRequired:
+		return fmt.Errorf("%w: %s", ErrCardDeclined, e.Code)
+	case e.Code == "resource_missing" || status == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, e.Message)
+	case e.Code == "payment_intent_unexpected_state" || e.Code == "charge_already_refunded":
+		return fmt.Errorf("%w: %s", ErrInvalidState, e.Message)
+	case status/100 == 4:
+		return fmt.Errorf("%w: %s", ErrInvalidRequest, e.Message)
+	default:
+		return fmt.Errorf("stripe: unexpected status %d: %s", status, e.Message)
+	}
+}
+
+func (g *StripeGateway) SaveCard(ctx context.Context, req SaveCardRequest) (*Card, error) {
+	if req.Token == "" {
+		return nil, ErrInvalidRequest
+	}
+	customerID := req.CustomerID
+	if customerID == "" {
+		var cus struct {
+			ID string `json:"id"`
+		}
+		form := url.Values{}
+		if req.Email != "" {
+			form.Set("email", req.Email)
+		}
+		if err := g.post(ctx, "/v1/customers", form, "", &cus); err != nil {
+			return nil, err
+		}
+		customerID = cus.ID
+	}
+
+	var pm stripePaymentMethod
+	form := url.Values{"customer": {customerID}}
+	if err := g.post(ctx, "/v1/payment_methods/"+url.PathEscape(req.Token)+"/attach", form, "", &pm); err != nil {
+		return nil, err
+	}
+	return &Card{
+		CustomerID: customerID,
+		MethodID:   pm.ID,
+		Brand:      pm.Card.Brand,
+		Last4:      pm.Card.Last4,
+		ExpMonth:   pm.Card.ExpMonth,
+		ExpYear:    pm.Card.ExpYear,
+	}, nil
+}
+
+func (g *StripeGateway) Authorize(ctx context.Context, req AuthorizeRequest) (*Charge, error) {
+	if req.AmountCents <= 0 || req.Currency == "" {
+		return nil, ErrInvalidRequest
+	}
+	form := url.Values{
+		"amount":         {strconv.FormatInt(req.AmountCents, 10)},
+		"currency":       {strings.ToLower(req.Currency)},
+		"customer":       {req.CustomerID},
+		"payment_method": {req.MethodID},
+		"capture_method": {"manual"},
+		"confirm":        {"true"},
+		"off_session":    {"true"},
+	}
+	if req.Description != "" {
+		form.Set("description", req.Description)
+	}
+	var pi stripeIntent
+	if err := g.post(ctx, "/v1/payment_intents", form, req.IdempotencyKey, &pi); err != nil {
+		return nil, err
+	}
+	if pi.Status != StatusRequiresCapture {
+		// e.g. requires_action for 3DS; off-session holds can't complete that, so treat it as a decline.
+		return nil, fmt.Errorf("%w: intent status %s", ErrCardDeclined, pi.Status)
+	}
+	return pi.charge(), nil
+}
+
+func (g *StripeGateway) Capture(ctx context.Context, chargeID string, amountCents int64, idempotencyKey string) (*Charge, error) {
+	form := url.Values{}
+	if amountCents > 0 {
+		form.Set("amount_to_capture", strconv.FormatInt(amountCents, 10))
+	}
+	var pi stripeIntent
+	if err := g.post(ctx, "/v1/payment_intents/"+url.PathEscape(chargeID)+"/capture", form, idempotencyKey, &pi); err != nil {
+		return nil, err
+	}
+	return pi.charge(), nil
+}
+
+func (g *StripeGateway) Void(ctx context.Context, chargeID string, idempotencyKey string) (*Charge, error) {
+	var pi stripeIntent
+	if err := g.post(ctx, "/v1/payment_intents/"+url.PathEscape(chargeID)+"/cancel", url.Values{}, idempotencyKey, &pi); err != nil {
+		return nil, err
+	}
+	return pi.charge(), nil
+}
+
+func (g *StripeGateway) Refund(ctx context.Context, chargeID string, amountCents int64, idempotencyKey string) (*Refund, error) {
+	form := url.Values{"payment_intent": {chargeID}}
+	if amountCents > 0 {
+		form.Set("amount", strconv.FormatInt(amountCents, 10))
+	}
+	var re stripeRefund
+	if err := g.post(ctx, "/v1/refunds", form, idempotencyKey, &re); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: re.ID, ChargeID: re.PaymentIntent, AmountCents: re.Amount, Status: re.Status}, nil
+}
+
+var _ PaymentGateway = (*StripeGateway)(nil)
@@ -0,0 +1,77 @@
+package promo
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	KindPercentage = "percentage"
+	KindFixed      = "fixed"
+)
+
+var (
+	ErrFareTooLow      = errors.New("fare is below the promo minimum")
+	ErrClassNotAllowed = errors.New("promo does not apply to this vehicle class")
+	ErrZoneNotAllowed  = errors.New("promo does not apply in this area")
+	ErrNotFirstRide    = errors.New("promo is only valid on a first ride")
+)
+
+// Rules is what a promo code takes off and which trips it applies to. It is embedded in
+// store.PromoCode, so the store can apply it while redeeming.
+type Rules struct {
+	Kind             string
+	PercentBps       int
+	AmountCents      int
+	MaxDiscountCents *int
+	MinFareCents     int
+	// VehicleClasses and ZoneIDs restrict eligibility; nil means any.
+	VehicleClasses []string
+	ZoneIDs        []uuid.UUID
+	FirstRideOnly  bool
+}
+
+// Trip is what a promo's eligibility rules are checked against.
+type Trip struct {
+	FareCents    int64
+	VehicleClass string
+	// ZoneIDs are the pricing zones at either end of the trip.
+	ZoneIDs   []uuid.UUID
+	FirstRide bool
+}
+
+// Check applies the code's static eligibility rules. Validity window and usage limits are checked by
+// the store when redeeming, under a lock.
+func Check(p *Rules, t Trip) error {
+	if t.FareCents < int64(p.MinFareCents) {
+		return ErrFareTooLow
+	}
+	if p.VehicleClasses != nil && !slices.Contains(p.VehicleClasses, t.VehicleClass) {
+		return ErrClassNotAllowed
+	}
+	if p.ZoneIDs != nil && !slices.ContainsFunc(t.ZoneIDs, func(id uuid.UUID) bool { return slices.Contains(p.ZoneIDs, id) }) {
+		return ErrZoneNotAllowed
+	}
+	if p.FirstRideOnly && !t.FirstRide {
+		return ErrNotFirstRide
+	}
+	return nil
+}
+
+// Discount is the amount the code takes off fareCents, capped by max_discount_cents and by the fare.
+// Percentages round half-up to the cent.
+func Discount(p *Rules, fareCents int64) int64 {
+	var d int64
+	switch p.Kind {
+	case KindPercentage:
+		d = (fareCents*int64(p.PercentBps) + 5000) / 10000
+	case KindFixed:
+		d = int64(p.AmountCents)
+	}
+	if p.MaxDiscountCents != nil {
+		d = min(d, int64(*p.MaxDiscountCents))
+	}
+	return max(min(d, fareCents), 0)
+}
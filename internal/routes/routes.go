@@ -16,31 +16,150 @@ func SetRouter(app *app.Application) *chi.Mux {
 	corsConfig := customMiddleware.DefaultCORSConfig()
 	r.Use(customMiddleware.CORS(corsConfig))
 
-	rateLimiter := customMiddleware.NewRateLimiter(100, time.Minute)
-	r.Use(customMiddleware.RateLimit(rateLimiter))
-
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(logger.HandlerLogger)
 	r.Use(middleware.Recoverer)
 
-	r.Get("/healthz", app.HealthHandler.HandleHealth)
+	// Provider webhooks are signed and arrive in bursts from a handful of provider IPs, so they
+	// skip the per-IP limit; a 429 would only make the provider retry.
+	r.Post("/api/v1/webhooks/payments", app.WebhookHandler.HandlePaymentWebhook)
+
+	rateLimiter := customMiddleware.NewRateLimiter(100, time.Minute)
+	limited := r.With(customMiddleware.RateLimit(rateLimiter))
+
+	limited.Get("/healthz", app.HealthHandler.HandleHealth)
 
-	r.Route("/api/v1", func(api chi.Router) {
+	limited.Route("/api/v1", func(api chi.Router) {
 		api.Post("/auth/login", app.UserHandler.HandleLogin)
+		api.Get("/documents/{documentID}/download", app.DocumentHandler.HandleDownload)
 
 		api.Group(func(protected chi.Router) {
 			protected.Use(customMiddleware.RequireJWT(app.Signer))
+			protected.Use(customMiddleware.Idempotency(app.IdempotencyStore))
+
+			protected.Post("/drivers/applications", app.DriverHandler.HandleApply)
+			protected.Get("/drivers/applications/me", app.DriverHandler.HandleGetMyApplication)
+			protected.Post("/drivers/documents", app.DocumentHandler.HandleUpload)
+			protected.Get("/drivers/documents", app.DocumentHandler.HandleListMine)
+			protected.Get("/drivers/documents/{documentID}/url", app.DocumentHandler.HandleMyDownloadURL)
+			protected.Get("/service-areas/check", app.AreaHandler.HandleCheck)
+			protected.Get("/service-areas/flat-rate", app.AreaHandler.HandleFlatRate)
+			protected.Post("/payments/methods", app.PaymentHandler.HandleSaveMethod)
+			protected.Get("/payments/methods", app.PaymentHandler.HandleListMethods)
+			protected.Put("/payments/methods/{methodID}/default", app.PaymentHandler.HandleSetDefaultMethod)
+			protected.Delete("/payments/methods/{methodID}", app.PaymentHandler.HandleRemoveMethod)
+			protected.Get("/payments/intents", app.PaymentHandler.HandleListIntents)
+			protected.Post("/promos/validate", app.PromoHandler.HandleValidate)
+			protected.Get("/credits", app.PromoHandler.HandleMyCredits)
 		})
 
 		api.Group(func(adminOnly chi.Router) {
 			adminOnly.Use(customMiddleware.RequireJWT(app.Signer))
 			adminOnly.Use(customMiddleware.RequireRole("admin"))
+			adminOnly.Use(customMiddleware.Idempotency(app.IdempotencyStore))
+
+			adminOnly.Route("/admin/drivers", func(drivers chi.Router) {
+				drivers.Get("/", app.DriverHandler.HandleList)
+				drivers.Get("/available", app.ScheduleHandler.HandleAvailableDrivers)
+				drivers.Get("/documents/expiring", app.DocumentHandler.HandleListExpiring)
+				drivers.Get("/{driverID}", app.DriverHandler.HandleGet)
+				drivers.Put("/{driverID}/background-check", app.DriverHandler.HandleSetBackgroundCheck)
+				drivers.Post("/{driverID}/approve", app.DriverHandler.HandleApprove)
+				drivers.Post("/{driverID}/reject", app.DriverHandler.HandleReject)
+				drivers.Get("/{driverID}/documents", app.DocumentHandler.HandleListForDriver)
+				drivers.Get("/{driverID}/documents/{documentID}/url", app.DocumentHandler.HandleDownloadURL)
+				drivers.Get("/{driverID}/assignments", app.VehicleHandler.HandleDriverAssignments)
+				drivers.Get("/{driverID}/location", app.LocationHandler.HandleLatest)
+				drivers.Get("/{driverID}/breadcrumbs", app.LocationHandler.HandleBreadcrumbs)
+			})
+
+			adminOnly.Route("/admin/vehicles", func(vehicles chi.Router) {
+				vehicles.Post("/", app.VehicleHandler.HandleCreate)
+				vehicles.Get("/", app.VehicleHandler.HandleList)
+				vehicles.Get("/{vehicleID}", app.VehicleHandler.HandleGet)
+				vehicles.Patch("/{vehicleID}", app.VehicleHandler.HandleUpdate)
+				vehicles.Delete("/{vehicleID}", app.VehicleHandler.HandleRetire)
+				vehicles.Get("/{vehicleID}/assignments", app.VehicleHandler.HandleVehicleAssignments)
+				vehicles.Post("/{vehicleID}/assignments", app.VehicleHandler.HandleAssign)
+				vehicles.Delete("/{vehicleID}/assignments/current", app.VehicleHandler.HandleUnassign)
+				vehicles.Put("/{vehicleID}/odometer", app.MaintenanceHandler.HandleUpdateOdometer)
+
+				vehicles.Get("/maintenance/due", app.MaintenanceHandler.HandleListDue)
+				vehicles.Get("/{vehicleID}/maintenance/schedules", app.MaintenanceHandler.HandleListSchedules)
+				vehicles.Post("/{vehicleID}/maintenance/schedules", app.MaintenanceHandler.HandleCreateSchedule)
+				vehicles.Delete("/{vehicleID}/maintenance/schedules/{scheduleID}", app.MaintenanceHandler.HandleDeleteSchedule)
+				vehicles.Get("/{vehicleID}/maintenance/records", app.MaintenanceHandler.HandleListRecords)
+				vehicles.Post("/{vehicleID}/maintenance/records", app.MaintenanceHandler.HandleCreateRecord)
+				vehicles.Post("/{vehicleID}/maintenance/records/{recordID}/start", app.MaintenanceHandler.HandleStartRecord)
+				vehicles.Post("/{vehicleID}/maintenance/records/{recordID}/complete", app.MaintenanceHandler.HandleCompleteRecord)
+				vehicles.Post("/{vehicleID}/maintenance/records/{recordID}/cancel", app.MaintenanceHandler.HandleCancelRecord)
+			})
+
+			adminOnly.Route("/admin/areas", func(areas chi.Router) {
+				areas.Post("/import", app.AreaHandler.HandleImport)
+				areas.Get("/", app.AreaHandler.HandleExport)
+				areas.Get("/{areaID}", app.AreaHandler.HandleGet)
+				areas.Delete("/{areaID}", app.AreaHandler.HandleDelete)
+			})
+
+			adminOnly.Route("/admin/zone-rates", func(rates chi.Router) {
+				rates.Put("/", app.AreaHandler.HandleUpsertRate)
+				rates.Get("/", app.AreaHandler.HandleListRates)
+				rates.Delete("/{rateID}", app.AreaHandler.HandleDeleteRate)
+			})
+
+			adminOnly.Route("/admin/tax-rules", func(taxes chi.Router) {
+				taxes.Post("/", app.TaxHandler.HandleCreate)
+				taxes.Get("/", app.TaxHandler.HandleList)
+				taxes.Put("/{ruleID}", app.TaxHandler.HandleUpdate)
+				taxes.Delete("/{ruleID}", app.TaxHandler.HandleDelete)
+			})
+
+			adminOnly.Route("/admin/payments", func(pay chi.Router) {
+				pay.Get("/{intentID}", app.AdjustmentHandler.HandleGetPayment)
+				pay.Post("/{intentID}/adjustments", app.AdjustmentHandler.HandleCreate)
+			})
+
+			adminOnly.Route("/admin/adjustments", func(adj chi.Router) {
+				adj.Get("/", app.AdjustmentHandler.HandleList)
+				adj.Post("/{adjustmentID}/approve", app.AdjustmentHandler.HandleApprove)
+				adj.Post("/{adjustmentID}/reject", app.AdjustmentHandler.HandleReject)
+			})
+
+			adminOnly.Route("/admin/promos", func(promos chi.Router) {
+				promos.Post("/", app.PromoHandler.HandleCreate)
+				promos.Get("/", app.PromoHandler.HandleList)
+				promos.Put("/{promoID}/active", app.PromoHandler.HandleSetActive)
+			})
+
+			adminOnly.Route("/admin/users/{userID}/credits", func(credits chi.Router) {
+				credits.Get("/", app.PromoHandler.HandleUserCredits)
+				credits.Post("/", app.PromoHandler.HandleAddCredit)
+			})
+
+			adminOnly.Route("/admin/shifts", func(shifts chi.Router) {
+				shifts.Post("/", app.ScheduleHandler.HandleCreateShift)
+				shifts.Get("/", app.ScheduleHandler.HandleListShifts)
+				shifts.Delete("/{shiftID}", app.ScheduleHandler.HandleDeleteShift)
+			})
 		})
 
 		api.Group(func(driverOnly chi.Router) {
 			driverOnly.Use(customMiddleware.RequireJWT(app.Signer))
 			driverOnly.Use(customMiddleware.RequireRole("driver"))
+			driverOnly.Use(customMiddleware.Idempotency(app.IdempotencyStore))
+
+			driverOnly.Route("/driver", func(driver chi.Router) {
+				driver.Get("/availability", app.ScheduleHandler.HandleGetAvailability)
+				driver.Put("/availability", app.ScheduleHandler.HandleSetAvailability)
+				driver.Get("/time-off", app.ScheduleHandler.HandleListTimeOff)
+				driver.Post("/time-off", app.ScheduleHandler.HandleCreateTimeOff)
+				driver.Delete("/time-off/{timeOffID}", app.ScheduleHandler.HandleDeleteTimeOff)
+				driver.Put("/duty", app.ScheduleHandler.HandleSetDuty)
+				driver.Get("/shifts", app.ScheduleHandler.HandleMyShifts)
+				driver.Post("/locations", app.LocationHandler.HandleIngest)
+			})
 		})
 	})
 
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	AdjustmentRefund = "refund"
+	AdjustmentCharge = "charge"
+
+	AdjustmentPendingApproval = "pending_approval"
+	AdjustmentProcessing      = "processing"
+	AdjustmentApplied         = "applied"
+	AdjustmentRejected        = "rejected"
+	AdjustmentFailed          = "failed"
+)
+
+// AdjustmentReasons are the reason codes accepted for adjustments, matching the adjustment_reason enum.
+var AdjustmentReasons = map[string]bool{
+	"service_issue": true, "overcharge": true, "goodwill": true, "toll": true,
+	"parking": true, "damage": true, "cleaning": true, "other": true,
+}
+
+var (
+	ErrPaymentNotCaptured    = errors.New("payment has not been captured")
+	ErrRefundExceedsCaptured = errors.New("refund exceeds the refundable amount")
+	ErrAdjustmentNotPending  = errors.New("adjustment is not awaiting approval")
+	ErrSelfApproval          = errors.New("adjustment must be decided by a different admin")
+)
+
+type Adjustment struct {
+	ID              uuid.UUID
+	PaymentIntentID uuid.UUID
+	Kind            string
+	Reason          string
+	AmountCents     int64
+	Note            sql.NullString
+	Status          string
+	RequestedBy     uuid.UUID
+	DecidedBy       *uuid.UUID
+	DecidedAt       sql.NullTime
+	DecisionNote    sql.NullString
+	ProviderRef     sql.NullString
+	ChargeIntentID  *uuid.UUID
+	FailureReason   sql.NullString
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// AdjustmentResult is the outcome of the gateway call for a processing adjustment.
+type AdjustmentResult struct {
+	Applied        bool
+	ProviderRef    string
+	ChargeIntentID *uuid.UUID
+	FailureReason  string
+}
+
+type AdjustmentStore interface {
+	// Create records an adjustment against a succeeded payment. It starts in processing when it, together
+	// with the intent's other applied and in-flight adjustments created within window, stays within
+	// approvalThreshold; otherwise it waits in pending_approval for a second admin. Refunds are also
+	// checked against the captured amount less refunds already made or in flight.
+	Create(ctx context.Context, a *Adjustment, approvalThreshold int64, window time.Duration) (*Adjustment, error)
+	Get(ctx context.Context, id uuid.UUID) (*Adjustment, error)
+	ListByIntent(ctx context.Context, intentID uuid.UUID) ([]Adjustment, error)
+	ListByStatus(ctx context.Context, status string, limit, offset int) ([]Adjustment, error)
+	// ListStuck returns adjustments that have been processing since before the given time.
+	ListStuck(ctx context.Context, before time.Time, limit int) ([]Adjustment, error)
+	// Approve moves a pending adjustment to processing; the approver must not be the requester.
+	Approve(ctx context.Context, id, adminID uuid.UUID, note string) (*Adjustment, error)
+	Reject(ctx context.Context, id, adminID uuid.UUID, note string) (*Adjustment, error)
+	// Finish records the gateway outcome. An applied refund is reflected in the intent's refunded
+	// amount in the same transaction.
+	Finish(ctx context.Context, id uuid.UUID, res AdjustmentResult) (*Adjustment, error)
+}
+
+type PostgresAdjustmentStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAdjustmentStore(pool *pgxpool.Pool) *PostgresAdjustmentStore {
+	return &PostgresAdjustmentStore{pool: pool}
+}
+
+const adjustmentColumns = `
+	id, payment_intent_id, kind, reason, amount_cents, note, status, requested_by, decided_by, decided_at,
+	decision_note, provider_ref, charge_intent_id, failure_reason, created_at, updated_at`
+
+func scanAdjustment(row pgx.Row, a *Adjustment) error {
+	return row.Scan(&a.ID, &a.PaymentIntentID, &a.Kind, &a.Reason, &a.AmountCents, &a.Note, &a.Status,
+		&a.RequestedBy, &a.DecidedBy, &a.DecidedAt, &a.DecisionNote, &a.ProviderRef, &a.ChargeIntentID,
+		&a.FailureReason, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func (s *PostgresAdjustmentStore) Create(ctx context.Context, a *Adjustment, approvalThreshold int64, window time.Duration) (*Adjustment, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the intent serializes concurrent adjustments against it, so the totals below can't go stale.
+	var status string
+	var captured, refunded int64
+	if err := tx.QueryRow(ctx, `
+		SELECT status, amount_captured_cents, amount_refunded_cents
+		FROM payment_intents
+		WHERE id = $1
+		FOR UPDATE
+	`, a.PaymentIntentID).Scan(&status, &captured, &refunded); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if status != PaymentIntentSucceeded || captured == 0 {
+		return nil, ErrPaymentNotCaptured
+	}
+	if a.Kind == AdjustmentRefund {
+		var inFlight int64
+		if err := tx.QueryRow(ctx, `
+			SELECT COALESCE(SUM(amount_cents), 0)
+			FROM payment_adjustments
+			WHERE payment_intent_id = $1 AND kind = 'refund' AND status IN ('pending_approval', 'processing')
+		`, a.PaymentIntentID).Scan(&inFlight); err != nil {
+			return nil, err
+		}
+		if a.AmountCents > captured-refunded-inFlight {
+			return nil, ErrRefundExceedsCaptured
+		}
+	}
+
+	// Splitting a large adjustment into several small ones must not get around the second-admin check.
+	var recent int64
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount_cents), 0)
+		FROM payment_adjustments
+		WHERE payment_intent_id = $1
+		  AND status IN ('pending_approval', 'processing', 'applied')
+		  AND created_at > now() - make_interval(secs => $2)
+	`, a.PaymentIntentID, window.Seconds()).Scan(&recent); err != nil {
+		return nil, err
+	}
+	initial := AdjustmentProcessing
+	if recent+a.AmountCents > approvalThreshold {
+		initial = AdjustmentPendingApproval
+	}
+
+	q := `
+		INSERT INTO payment_adjustments (payment_intent_id, kind, reason, amount_cents, note, status, requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + adjustmentColumns
+	var out Adjustment
+	if err := scanAdjustment(tx.QueryRow(ctx, q,
+		a.PaymentIntentID, a.Kind, a.Reason, a.AmountCents, a.Note, initial, a.RequestedBy,
+	), &out); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresAdjustmentStore) Get(ctx context.Context, id uuid.UUID) (*Adjustment, error) {
+	q := `SELECT ` + adjustmentColumns + ` FROM payment_adjustments WHERE id = $1 LIMIT 1;`
+	var a Adjustment
+	if err := scanAdjustment(s.pool.QueryRow(ctx, q, id), &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *PostgresAdjustmentStore) list(ctx context.Context, q string, args ...any) ([]Adjustment, error) {
+	rows, err := s.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Adjustment, 0)
+	for rows.Next() {
+		var a Adjustment
+		if err := scanAdjustment(rows, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresAdjustmentStore) ListByIntent(ctx context.Context, intentID uuid.UUID) ([]Adjustment, error) {
+	return s.list(ctx, `
+		SELECT `+adjustmentColumns+`
+		FROM payment_adjustments
+		WHERE payment_intent_id = $1
+		ORDER BY created_at ASC;
+	`, intentID)
+}
+
+func (s *PostgresAdjustmentStore) ListByStatus(ctx context.Context, status string, limit, offset int) ([]Adjustment, error) {
+	return s.list(ctx, `
+		SELECT `+adjustmentColumns+`
+		FROM payment_adjustments
+		WHERE ($1 = '' OR status = $1::adjustment_status)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3;
+	`, status, limit, offset)
+}
+
+func (s *PostgresAdjustmentStore) ListStuck(ctx context.Context, before time.Time, limit int) ([]Adjustment, error) {
+	return s.list(ctx, `
+		SELECT `+adjustmentColumns+`
+		FROM payment_adjustments
+		WHERE status = 'processing' AND updated_at < $1
+		ORDER BY updated_at ASC
+		LIMIT $2;
+	`, before, limit)
+}
+
+// decide moves a pending adjustment to status on behalf of a second admin.
+func (s *PostgresAdjustmentStore) decide(ctx context.Context, id, adminID uuid.UUID, note, status string) (*Adjustment, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var current string
+	var requestedBy uuid.UUID
+	if err := tx.QueryRow(ctx, `
+		SELECT status, requested_by FROM payment_adjustments WHERE id = $1 FOR UPDATE
+	`, id).Scan(&current, &requestedBy); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if current != AdjustmentPendingApproval {
+		return nil, ErrAdjustmentNotPending
+	}
+	if requestedBy == adminID {
+		return nil, ErrSelfApproval
+	}
+
+	q := `
+		UPDATE payment_adjustments
+		SET status = $2, decided_by = $3, decided_at = now(), decision_note = $4
+		WHERE id = $1
+		RETURNING ` + adjustmentColumns
+	var out Adjustment
+	if err := scanAdjustment(tx.QueryRow(ctx, q, id, status, adminID, ToNullString(note)), &out); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresAdjustmentStore) Approve(ctx context.Context, id, adminID uuid.UUID, note string) (*Adjustment, error) {
+	return s.decide(ctx, id, adminID, note, AdjustmentProcessing)
+}
+
+func (s *PostgresAdjustmentStore) Reject(ctx context.Context, id, adminID uuid.UUID, note string) (*Adjustment, error) {
+	return s.decide(ctx, id, adminID, note, AdjustmentRejected)
+}
+
+func (s *PostgresAdjustmentStore) Finish(ctx context.Context, id uuid.UUID, res AdjustmentResult) (*Adjustment, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	status := AdjustmentFailed
+	if res.Applied {
+		status = AdjustmentApplied
+	}
+	q := `
+		UPDATE payment_adjustments
+		SET status = $2, provider_ref = $3, charge_intent_id = $4, failure_reason = $5
+		WHERE id = $1 AND status = 'processing'
+		RETURNING ` + adjustmentColumns
+	var out Adjustment
+	if err := scanAdjustment(tx.QueryRow(ctx, q,
+		id, status, ToNullString(res.ProviderRef), res.ChargeIntentID, ToNullString(res.FailureReason),
+	), &out); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAdjustmentNotPending
+		}
+		return nil, err
+	}
+
+	if res.Applied && out.Kind == AdjustmentRefund {
+		// The provider's refund webhook may already have raised the total, so take the larger of that and
+		// our own applied refunds rather than adding on top of it.
+		if _, err := tx.Exec(ctx, `
+			UPDATE payment_intents
+			SET amount_refunded_cents = LEAST(amount_captured_cents, GREATEST(amount_refunded_cents, (
+				SELECT COALESCE(SUM(amount_cents), 0)
+				FROM payment_adjustments
+				WHERE payment_intent_id = $1 AND kind = 'refund' AND status = 'applied'
+			)))
+			WHERE id = $1
+		`, out.PaymentIntentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+var _ AdjustmentStore = (*PostgresAdjustmentStore)(nil)
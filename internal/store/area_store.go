@@ -0,0 +1,334 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/geo"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	AreaKindServiceArea = "service_area"
+	AreaKindZone        = "zone"
+)
+
+var ErrNotAZone = errors.New("area is not a pricing zone")
+
+type ServiceArea struct {
+	ID        uuid.UUID
+	Name      string
+	Kind      string
+	Geometry  geo.MultiPolygon
+	BBox      geo.BBox
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ZoneRate struct {
+	ID           uuid.UUID
+	FromZoneID   uuid.UUID
+	ToZoneID     uuid.UUID
+	VehicleClass string
+	AmountCents  int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type AreaStore interface {
+	// Import creates each area or replaces the geometry, kind and active flag of the one with the same
+	// name, so re-importing a GeoJSON file is idempotent. All areas are written in one transaction. An
+	// area that stops being a zone loses its zone rates.
+	Import(ctx context.Context, areas []*ServiceArea) ([]ServiceArea, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*ServiceArea, error)
+	List(ctx context.Context, kind string) ([]ServiceArea, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Containing returns active areas of the given kind whose geometry contains p, smallest first
+	// so the most specific zone (an airport inside downtown) comes before the one enclosing it.
+	Containing(ctx context.Context, p geo.Point, kind string) ([]ServiceArea, error)
+
+	UpsertRate(ctx context.Context, zr *ZoneRate) (*ZoneRate, error)
+	ListRates(ctx context.Context) ([]ZoneRate, error)
+	DeleteRate(ctx context.Context, id uuid.UUID) error
+	// FindRate returns the flat rate for the first (from, to) zone pair that has one, trying pairs in order.
+	FindRate(ctx context.Context, fromZones, toZones []uuid.UUID, class string) (*ZoneRate, error)
+}
+
+type PostgresAreaStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAreaStore(pool *pgxpool.Pool) *PostgresAreaStore {
+	return &PostgresAreaStore{pool: pool}
+}
+
+const areaColumns = `id, name, kind, geometry, min_lat, min_lng, max_lat, max_lng, active, created_at, updated_at`
+
+func scanArea(row pgx.Row, a *ServiceArea) error {
+	var raw []byte
+	if err := row.Scan(&a.ID, &a.Name, &a.Kind, &raw,
+		&a.BBox.MinLat, &a.BBox.MinLng, &a.BBox.MaxLat, &a.BBox.MaxLng,
+		&a.Active, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return err
+	}
+	mp, err := geo.ParseGeometry(raw)
+	if err != nil {
+		return err
+	}
+	a.Geometry = mp
+	return nil
+}
+
+func collectAreas(rows pgx.Rows) ([]ServiceArea, error) {
+	defer rows.Close()
+	out := make([]ServiceArea, 0)
+	for rows.Next() {
+		var a ServiceArea
+		if err := scanArea(rows, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresAreaStore) Import(ctx context.Context, areas []*ServiceArea) ([]ServiceArea, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	out := make([]ServiceArea, 0, len(areas))
+	for _, a := range areas {
+		saved, err := upsertArea(ctx, tx, a)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *saved)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func upsertArea(ctx context.Context, tx pgx.Tx, a *ServiceArea) (*ServiceArea, error) {
+	raw, err := a.Geometry.MarshalGeoJSON()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(a.Name)
+
+	var prevKind string
+	if err := tx.QueryRow(ctx, `SELECT kind FROM service_areas WHERE name = $1 FOR UPDATE`, name).Scan(&prevKind); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	bb := a.Geometry.BBox()
+	q := `
+		INSERT INTO service_areas (name, kind, geometry, min_lat, min_lng, max_lat, max_lng, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (name) DO UPDATE SET
+			kind = EXCLUDED.kind,
+			geometry = EXCLUDED.geometry,
+			min_lat = EXCLUDED.min_lat,
+			min_lng = EXCLUDED.min_lng,
+			max_lat = EXCLUDED.max_lat,
+			max_lng = EXCLUDED.max_lng,
+			active = EXCLUDED.active
+		RETURNING ` + areaColumns
+	var out ServiceArea
+	if err := scanArea(tx.QueryRow(ctx, q,
+		name, a.Kind, raw, bb.MinLat, bb.MinLng, bb.MaxLat, bb.MaxLng, a.Active,
+	), &out); err != nil {
+		return nil, err
+	}
+
+	if prevKind == AreaKindZone && out.Kind != AreaKindZone {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM zone_rates WHERE from_zone_id = $1 OR to_zone_id = $1
+		`, out.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &out, nil
+}
+
+func (s *PostgresAreaStore) GetByID(ctx context.Context, id uuid.UUID) (*ServiceArea, error) {
+	q := `SELECT ` + areaColumns + ` FROM service_areas WHERE id = $1 LIMIT 1;`
+	var a ServiceArea
+	if err := scanArea(s.pool.QueryRow(ctx, q, id), &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List returns areas ordered by name; an empty kind returns both kinds.
+func (s *PostgresAreaStore) List(ctx context.Context, kind string) ([]ServiceArea, error) {
+	q := `
+		SELECT ` + areaColumns + `
+		FROM service_areas
+		WHERE ($1 = '' OR kind::text = $1)
+		ORDER BY name;
+	`
+	rows, err := s.pool.Query(ctx, q, kind)
+	if err != nil {
+		return nil, err
+	}
+	return collectAreas(rows)
+}
+
+func (s *PostgresAreaStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM service_areas WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresAreaStore) Containing(ctx context.Context, p geo.Point, kind string) ([]ServiceArea, error) {
+	// The bounding box narrows candidates in SQL; the exact polygon test runs in Go.
+	q := `
+		SELECT ` + areaColumns + `
+		FROM service_areas
+		WHERE active AND kind::text = $1
+		  AND $2 BETWEEN min_lat AND max_lat
+		  AND $3 BETWEEN min_lng AND max_lng
+		ORDER BY (max_lat - min_lat) * (max_lng - min_lng), name;
+	`
+	rows, err := s.pool.Query(ctx, q, kind, p.Lat, p.Lng)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := collectAreas(rows)
+	if err != nil {
+		return nil, err
+	}
+	out := candidates[:0]
+	for _, a := range candidates {
+		if a.Geometry.Contains(p) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+const zoneRateColumns = `id, from_zone_id, to_zone_id, vehicle_class, amount_cents, created_at, updated_at`
+
+func scanZoneRate(row pgx.Row, zr *ZoneRate) error {
+	return row.Scan(&zr.ID, &zr.FromZoneID, &zr.ToZoneID, &zr.VehicleClass, &zr.AmountCents, &zr.CreatedAt, &zr.UpdatedAt)
+}
+
+func (s *PostgresAreaStore) UpsertRate(ctx context.Context, zr *ZoneRate) (*ZoneRate, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var zones int
+	if err := tx.QueryRow(ctx, `
+		SELECT count(*) FROM (
+			SELECT id FROM service_areas
+			WHERE id IN ($1, $2) AND kind = 'zone'
+			FOR SHARE
+		) z
+	`, zr.FromZoneID, zr.ToZoneID).Scan(&zones); err != nil {
+		return nil, err
+	}
+	want := 2
+	if zr.FromZoneID == zr.ToZoneID {
+		want = 1
+	}
+	if zones != want {
+		return nil, ErrNotAZone
+	}
+
+	var out ZoneRate
+	if err := scanZoneRate(tx.QueryRow(ctx, `
+		INSERT INTO zone_rates (from_zone_id, to_zone_id, vehicle_class, amount_cents)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (from_zone_id, to_zone_id, vehicle_class) DO UPDATE SET amount_cents = EXCLUDED.amount_cents
+		RETURNING `+zoneRateColumns,
+		zr.FromZoneID, zr.ToZoneID, zr.VehicleClass, zr.AmountCents), &out); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresAreaStore) ListRates(ctx context.Context) ([]ZoneRate, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+prefixColumns("zr", zoneRateColumns)+`
+		FROM zone_rates zr
+		JOIN service_areas f ON f.id = zr.from_zone_id
+		JOIN service_areas t ON t.id = zr.to_zone_id
+		ORDER BY f.name, t.name, zr.vehicle_class
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ZoneRate, 0)
+	for rows.Next() {
+		var zr ZoneRate
+		if err := scanZoneRate(rows, &zr); err != nil {
+			return nil, err
+		}
+		out = append(out, zr)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresAreaStore) DeleteRate(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM zone_rates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresAreaStore) FindRate(ctx context.Context, fromZones, toZones []uuid.UUID, class string) (*ZoneRate, error) {
+	if len(fromZones) == 0 || len(toZones) == 0 {
+		return nil, ErrNotFound
+	}
+	q := `
+		SELECT ` + prefixColumns("zr", zoneRateColumns) + `
+		FROM zone_rates zr
+		JOIN unnest($1::uuid[]) WITH ORDINALITY AS f(id, ord) ON f.id = zr.from_zone_id
+		JOIN unnest($2::uuid[]) WITH ORDINALITY AS t(id, ord) ON t.id = zr.to_zone_id
+		WHERE zr.vehicle_class::text = $3
+		ORDER BY f.ord, t.ord
+		LIMIT 1;
+	`
+	var zr ZoneRate
+	if err := scanZoneRate(s.pool.QueryRow(ctx, q, fromZones, toZones, class), &zr); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &zr, nil
+}
+
+var _ AreaStore = (*PostgresAreaStore)(nil)
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	CreditGrant      = "grant"
+	CreditCorrection = "correction"
+	CreditSpend      = "spend"
+	CreditRestore    = "restore"
+)
+
+var ErrInsufficientCredit = errors.New("credit balance would go negative")
+
+type CreditEntry struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Kind        string
+	AmountCents int64
+	ReferenceID *uuid.UUID
+	Note        sql.NullString
+	CreatedBy   *uuid.UUID
+	CreatedAt   time.Time
+}
+
+type CreditStore interface {
+	// Add appends a ledger entry. Negative amounts are refused with ErrInsufficientCredit if they would
+	// take the balance below zero.
+	Add(ctx context.Context, e *CreditEntry) (*CreditEntry, int64, error)
+	// Spend applies up to maxCents of the user's balance to a fare and returns the entry (nil when the
+	// balance is empty) and the amount applied.
+	Spend(ctx context.Context, userID uuid.UUID, maxCents int64, referenceID uuid.UUID) (*CreditEntry, int64, error)
+	// Restore gives back whatever was spent against referenceID and not yet restored, e.g. when the
+	// booking it paid for is cancelled. It returns a nil entry when there is nothing to restore.
+	Restore(ctx context.Context, userID, referenceID uuid.UUID) (*CreditEntry, error)
+	Balance(ctx context.Context, userID uuid.UUID) (int64, error)
+	List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]CreditEntry, error)
+}
+
+type PostgresCreditStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresCreditStore(pool *pgxpool.Pool) *PostgresCreditStore {
+	return &PostgresCreditStore{pool: pool}
+}
+
+const creditColumns = `id, user_id, kind, amount_cents, reference_id, note, created_by, created_at`
+
+func scanCredit(row pgx.Row, e *CreditEntry) error {
+	return row.Scan(&e.ID, &e.UserID, &e.Kind, &e.AmountCents, &e.ReferenceID, &e.Note, &e.CreatedBy, &e.CreatedAt)
+}
+
+// lockedBalance locks the user row so concurrent ledger writes for the same user run one at a time,
+// then returns the current balance. FOR NO KEY UPDATE leaves inserts that reference the user (tokens,
+// payments) unblocked.
+func lockedBalance(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error) {
+	var id uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE`, userID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	var balance int64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount_cents), 0) FROM credit_entries WHERE user_id = $1
+	`, userID).Scan(&balance)
+	return balance, err
+}
+
+func insertCredit(ctx context.Context, tx pgx.Tx, e *CreditEntry) (*CreditEntry, error) {
+	var out CreditEntry
+	if err := scanCredit(tx.QueryRow(ctx, `
+		INSERT INTO credit_entries (user_id, kind, amount_cents, reference_id, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+creditColumns,
+		e.UserID, e.Kind, e.AmountCents, e.ReferenceID, e.Note, e.CreatedBy), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresCreditStore) Add(ctx context.Context, e *CreditEntry) (*CreditEntry, int64, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	balance, err := lockedBalance(ctx, tx, e.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if balance+e.AmountCents < 0 {
+		return nil, 0, ErrInsufficientCredit
+	}
+	out, err := insertCredit(ctx, tx, e)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, err
+	}
+	return out, balance + e.AmountCents, nil
+}
+
+func (s *PostgresCreditStore) Spend(ctx context.Context, userID uuid.UUID, maxCents int64, referenceID uuid.UUID) (*CreditEntry, int64, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	balance, err := lockedBalance(ctx, tx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	spend := min(balance, maxCents)
+	if spend <= 0 {
+		return nil, 0, nil
+	}
+	out, err := insertCredit(ctx, tx, &CreditEntry{
+		UserID:      userID,
+		Kind:        CreditSpend,
+		AmountCents: -spend,
+		ReferenceID: &referenceID,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, err
+	}
+	return out, spend, nil
+}
+
+func (s *PostgresCreditStore) Restore(ctx context.Context, userID, referenceID uuid.UUID) (*CreditEntry, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockedBalance(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	// Spends are negative and restores positive, so the net is what is still owed back.
+	var net int64
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount_cents), 0)
+		FROM credit_entries
+		WHERE user_id = $1 AND reference_id = $2 AND kind IN ('spend', 'restore')
+	`, userID, referenceID).Scan(&net); err != nil {
+		return nil, err
+	}
+	if net >= 0 {
+		return nil, nil
+	}
+	out, err := insertCredit(ctx, tx, &CreditEntry{
+		UserID:      userID,
+		Kind:        CreditRestore,
+		AmountCents: -net,
+		ReferenceID: &referenceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *PostgresCreditStore) Balance(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var balance int64
+	err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount_cents), 0) FROM credit_entries WHERE user_id = $1
+	`, userID).Scan(&balance)
+	return balance, err
+}
+
+func (s *PostgresCreditStore) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]CreditEntry, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+creditColumns+`
+		FROM credit_entries
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]CreditEntry, 0)
+	for rows.Next() {
+		var e CreditEntry
+		if err := scanCredit(rows, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+var _ CreditStore = (*PostgresCreditStore)(nil)
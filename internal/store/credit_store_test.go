@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreditSpendConcurrentNeverOverdraws(t *testing.T) {
+	pool := testPool(t)
+	cs := NewPostgresCreditStore(pool)
+	ctx := context.Background()
+	uid := testUser(t, pool)
+
+	if _, _, err := cs.Add(ctx, &CreditEntry{UserID: uid, Kind: CreditGrant, AmountCents: 1000, Note: sql.NullString{String: "test", Valid: true}}); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		spent int64
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, n, err := cs.Spend(ctx, uid, 300, uuid.New())
+			if err != nil {
+				t.Errorf("spend: %v", err)
+				return
+			}
+			mu.Lock()
+			spent += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if spent != 1000 {
+		t.Errorf("spent = %d, want 1000", spent)
+	}
+	balance, err := cs.Balance(ctx, uid)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance != 0 {
+		t.Errorf("balance = %d, want 0", balance)
+	}
+}
+
+func TestCreditRestoreIsIdempotent(t *testing.T) {
+	pool := testPool(t)
+	cs := NewPostgresCreditStore(pool)
+	ctx := context.Background()
+	uid := testUser(t, pool)
+	ref := uuid.New()
+
+	if _, _, err := cs.Add(ctx, &CreditEntry{UserID: uid, Kind: CreditGrant, AmountCents: 800}); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if _, n, err := cs.Spend(ctx, uid, 500, ref); err != nil || n != 500 {
+		t.Fatalf("spend = %d, %v; want 500", n, err)
+	}
+	e, err := cs.Restore(ctx, uid, ref)
+	if err != nil || e == nil || e.AmountCents != 500 {
+		t.Fatalf("restore = %+v, %v; want a 500 entry", e, err)
+	}
+	if e, err := cs.Restore(ctx, uid, ref); err != nil || e != nil {
+		t.Fatalf("second restore = %+v, %v; want nothing", e, err)
+	}
+	balance, err := cs.Balance(ctx, uid)
+	if err != nil || balance != 800 {
+		t.Fatalf("balance = %d, %v; want 800", balance, err)
+	}
+}
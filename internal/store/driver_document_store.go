@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	DocumentTypeLicense         = "license"
+	DocumentTypeInsurance       = "insurance"
+	DocumentTypeChauffeurPermit = "chauffeur_permit"
+)
+
+type DriverDocument struct {
+	ID          uuid.UUID
+	DriverID    uuid.UUID
+	DocType     string
+	StorageKey  string
+	FileName    string
+	ContentType string
+	SizeBytes   int64
+	SHA256      string
+	ExpiresOn   sql.NullTime
+	UploadedBy  *uuid.UUID
+	UploadedAt  time.Time
+}
+
+type DriverDocumentStore interface {
+	Create(ctx context.Context, d *DriverDocument) (*DriverDocument, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*DriverDocument, error)
+	ListByDriver(ctx context.Context, driverID uuid.UUID) ([]DriverDocument, error)
+	// ListExpiring returns the current document of each type per driver whose expiry falls on or before the given date.
+	ListExpiring(ctx context.Context, before time.Time, limit, offset int) ([]DriverDocument, error)
+}
+
+type PostgresDriverDocumentStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresDriverDocumentStore(pool *pgxpool.Pool) *PostgresDriverDocumentStore {
+	return &PostgresDriverDocumentStore{pool: pool}
+}
+
+const driverDocumentColumns = `
+	id, driver_id, doc_type, storage_key, file_name, content_type, size_bytes, sha256,
+	expires_on, uploaded_by, uploaded_at`
+
+func scanDriverDocument(row pgx.Row, d *DriverDocument) error {
+	return row.Scan(
+		&d.ID, &d.DriverID, &d.DocType, &d.StorageKey, &d.FileName, &d.ContentType, &d.SizeBytes, &d.SHA256,
+		&d.ExpiresOn, &d.UploadedBy, &d.UploadedAt,
+	)
+}
+
+func collectDriverDocuments(rows pgx.Rows) ([]DriverDocument, error) {
+	defer rows.Close()
+	out := make([]DriverDocument, 0)
+	for rows.Next() {
+		var d DriverDocument
+		if err := scanDriverDocument(rows, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresDriverDocumentStore) Create(ctx context.Context, d *DriverDocument) (*DriverDocument, error) {
+	q := `
+		INSERT INTO driver_documents
+			(driver_id, doc_type, storage_key, file_name, content_type, size_bytes, sha256, expires_on, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + driverDocumentColumns
+	var out DriverDocument
+	if err := scanDriverDocument(s.pool.QueryRow(ctx, q,
+		d.DriverID, d.DocType, d.StorageKey, d.FileName, d.ContentType, d.SizeBytes, d.SHA256, d.ExpiresOn, d.UploadedBy,
+	), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresDriverDocumentStore) GetByID(ctx context.Context, id uuid.UUID) (*DriverDocument, error) {
+	q := `SELECT ` + driverDocumentColumns + ` FROM driver_documents WHERE id = $1 LIMIT 1;`
+	var d DriverDocument
+	if err := scanDriverDocument(s.pool.QueryRow(ctx, q, id), &d); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *PostgresDriverDocumentStore) ListByDriver(ctx context.Context, driverID uuid.UUID) ([]DriverDocument, error) {
+	q := `
+		SELECT ` + driverDocumentColumns + `
+		FROM driver_documents
+		WHERE driver_id = $1
+		ORDER BY doc_type, uploaded_at DESC;
+	`
+	rows, err := s.pool.Query(ctx, q, driverID)
+	if err != nil {
+		return nil, err
+	}
+	return collectDriverDocuments(rows)
+}
+
+func (s *PostgresDriverDocumentStore) ListExpiring(ctx context.Context, before time.Time, limit, offset int) ([]DriverDocument, error) {
+	// Superseded uploads don't count: only the newest document of each type per driver is considered.
+	q := `
+		SELECT ` + driverDocumentColumns + `
+		FROM (
+			SELECT DISTINCT ON (driver_id, doc_type) ` + driverDocumentColumns + `
+			FROM driver_documents
+			ORDER BY driver_id, doc_type, uploaded_at DESC
+		) latest
+		WHERE expires_on IS NOT NULL AND expires_on <= $1
+		ORDER BY expires_on ASC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := s.pool.Query(ctx, q, before, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectDriverDocuments(rows)
+}
+
+var _ DriverDocumentStore = (*PostgresDriverDocumentStore)(nil)
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	DriverStatusPending  = "pending"
+	DriverStatusApproved = "approved"
+	DriverStatusRejected = "rejected"
+
+	BackgroundCheckNotStarted = "not_started"
+	BackgroundCheckPending    = "pending"
+	BackgroundCheckClear      = "clear"
+	BackgroundCheckFailed     = "failed"
+)
+
+var (
+	ErrDriverApplicationExists = errors.New("driver application already exists")
+	ErrDuplicateLicense        = errors.New("license number already registered")
+	ErrDriverNotPending        = errors.New("driver application is not pending review")
+	ErrBackgroundCheckNotClear = errors.New("background check has not cleared")
+	ErrStaffCannotDrive        = errors.New("admin accounts cannot apply as drivers")
+)
+
+type Driver struct {
+	ID                    uuid.UUID
+	UserID                uuid.UUID
+	FirstName             string
+	LastName              string
+	Phone                 string
+	LicenseNumber         string
+	LicenseState          string
+	LicenseExpiry         time.Time
+	HomeBase              string
+	BackgroundCheckStatus string
+	Rating                *float64
+	Status                string
+	RejectionReason       sql.NullString
+	ReviewedBy            *uuid.UUID
+	ReviewedAt            sql.NullTime
+	OnDuty                bool
+	DutyChangedAt         sql.NullTime
+	SubmittedAt           time.Time
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+type DriverStore interface {
+	// Submit creates an application for the user, or resubmits one that was previously rejected. Admin
+	// accounts are refused so that approving them can't strip their role.
+	Submit(ctx context.Context, d *Driver) (*Driver, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Driver, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*Driver, error)
+	List(ctx context.Context, status string, limit, offset int) ([]Driver, error)
+	SetBackgroundCheck(ctx context.Context, id uuid.UUID, status string) (*Driver, error)
+	// Approve marks a pending application approved and promotes the user to an active driver atomically.
+	Approve(ctx context.Context, id uuid.UUID, reviewerID uuid.UUID) (*Driver, error)
+	Reject(ctx context.Context, id uuid.UUID, reviewerID uuid.UUID, reason string) (*Driver, error)
+	// SetOnDuty toggles whether an approved driver is currently taking work.
+	SetOnDuty(ctx context.Context, id uuid.UUID, onDuty bool) (*Driver, error)
+}
+
+type PostgresDriverStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresDriverStore(pool *pgxpool.Pool) *PostgresDriverStore {
+	return &PostgresDriverStore{pool: pool}
+}
+
+const driverColumns = `
+	id, user_id, first_name, last_name, phone, license_number, license_state, license_expiry,
+	home_base, background_check_status, rating, status, rejection_reason, reviewed_by, reviewed_at,
+	on_duty, duty_changed_at, submitted_at, created_at, updated_at`
+
+// driverDest lists scan targets in driverColumns order, for queries that select extra columns after them.
+func driverDest(d *Driver) []any {
+	return []any{
+		&d.ID, &d.UserID, &d.FirstName, &d.LastName, &d.Phone, &d.LicenseNumber, &d.LicenseState, &d.LicenseExpiry,
+		&d.HomeBase, &d.BackgroundCheckStatus, &d.Rating, &d.Status, &d.RejectionReason, &d.ReviewedBy, &d.ReviewedAt,
+		&d.OnDuty, &d.DutyChangedAt, &d.SubmittedAt, &d.CreatedAt, &d.UpdatedAt,
+	}
+}
+
+func scanDriver(row pgx.Row, d *Driver) error {
+	return row.Scan(driverDest(d)...)
+}
+
+func normalizeLicense(n string) string { return strings.ToUpper(strings.TrimSpace(n)) }
+
+func (s *PostgresDriverStore) Submit(ctx context.Context, d *Driver) (*Driver, error) {
+	var role string
+	if err := s.pool.QueryRow(ctx, `SELECT role FROM users WHERE id = $1`, d.UserID).Scan(&role); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if role != "rider" && role != "driver" {
+		return nil, ErrStaffCannotDrive
+	}
+
+	// Only a rejected application may be overwritten; pending or approved rows are left alone
+	// and the conflict surfaces as no row returned.
+	q := `
+		INSERT INTO drivers
+			(user_id, first_name, last_name, phone, license_number, license_state, license_expiry, home_base)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE SET
+			first_name = EXCLUDED.first_name,
+			last_name = EXCLUDED.last_name,
+			phone = EXCLUDED.phone,
+			license_number = EXCLUDED.license_number,
+			license_state = EXCLUDED.license_state,
+			license_expiry = EXCLUDED.license_expiry,
+			home_base = EXCLUDED.home_base,
+			background_check_status = 'not_started',
+			status = 'pending',
+			rejection_reason = NULL,
+			reviewed_by = NULL,
+			reviewed_at = NULL,
+			submitted_at = now()
+		WHERE drivers.status = 'rejected'
+		RETURNING ` + driverColumns
+	var out Driver
+	err := scanDriver(s.pool.QueryRow(ctx, q,
+		d.UserID, strings.TrimSpace(d.FirstName), strings.TrimSpace(d.LastName), strings.TrimSpace(d.Phone),
+		normalizeLicense(d.LicenseNumber), strings.ToUpper(strings.TrimSpace(d.LicenseState)), d.LicenseExpiry,
+		strings.TrimSpace(d.HomeBase),
+	), &out)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDriverApplicationExists
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateLicense
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresDriverStore) GetByID(ctx context.Context, id uuid.UUID) (*Driver, error) {
+	q := `SELECT ` + driverColumns + ` FROM drivers WHERE id = $1 LIMIT 1;`
+	var d Driver
+	if err := scanDriver(s.pool.QueryRow(ctx, q, id), &d); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *PostgresDriverStore) GetByUserID(ctx context.Context, userID uuid.UUID) (*Driver, error) {
+	q := `SELECT ` + driverColumns + ` FROM drivers WHERE user_id = $1 LIMIT 1;`
+	var d Driver
+	if err := scanDriver(s.pool.QueryRow(ctx, q, userID), &d); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// List returns drivers ordered oldest submission first; an empty status returns every driver.
+func (s *PostgresDriverStore) List(ctx context.Context, status string, limit, offset int) ([]Driver, error) {
+	q := `
+		SELECT ` + driverColumns + `
+		FROM drivers
+		WHERE ($1 = '' OR status::text = $1)
+		ORDER BY submitted_at ASC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := s.pool.Query(ctx, q, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Driver, 0)
+	for rows.Next() {
+		var d Driver
+		if err := scanDriver(rows, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresDriverStore) SetBackgroundCheck(ctx context.Context, id uuid.UUID, status string) (*Driver, error) {
+	q := `
+		UPDATE drivers SET background_check_status = $2
+		WHERE id = $1
+		RETURNING ` + driverColumns
+	var d Driver
+	if err := scanDriver(s.pool.QueryRow(ctx, q, id, status), &d); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// lockPending locks the driver row and verifies the application is still awaiting review.
+func lockPending(ctx context.Context, tx pgx.Tx, id uuid.UUID) (check string, userID uuid.UUID, err error) {
+	var status string
+	if err = tx.QueryRow(ctx, `
+		SELECT status, background_check_status, user_id
+		FROM drivers
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&status, &check, &userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", uuid.Nil, ErrNotFound
+		}
+		return "", uuid.Nil, err
+	}
+	if status != DriverStatusPending {
+		return "", uuid.Nil, ErrDriverNotPending
+	}
+	return check, userID, nil
+}
+
+func (s *PostgresDriverStore) Approve(ctx context.Context, id uuid.UUID, reviewerID uuid.UUID) (*Driver, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	check, userID, err := lockPending(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if check != BackgroundCheckClear {
+		return nil, ErrBackgroundCheckNotClear
+	}
+
+	var d Driver
+	if err := scanDriver(tx.QueryRow(ctx, `
+		UPDATE drivers
+		SET status = 'approved', reviewed_by = $2, reviewed_at = now(), rejection_reason = NULL
+		WHERE id = $1
+		RETURNING `+driverColumns, id, reviewerID), &d); err != nil {
+		return nil, err
+	}
+
+	// Only riders are promoted; an account that became an admin after applying keeps its role and the
+	// application is refused.
+	var role string
+	if err := tx.QueryRow(ctx, `SELECT role FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&role); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if role != "rider" && role != "driver" {
+		return nil, ErrStaffCannotDrive
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET role = 'driver', is_active = true, updated_at = now() WHERE id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *PostgresDriverStore) Reject(ctx context.Context, id uuid.UUID, reviewerID uuid.UUID, reason string) (*Driver, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, _, err := lockPending(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	var d Driver
+	if err := scanDriver(tx.QueryRow(ctx, `
+		UPDATE drivers
+		SET status = 'rejected', reviewed_by = $2, reviewed_at = now(), rejection_reason = $3
+		WHERE id = $1
+		RETURNING `+driverColumns, id, reviewerID, ToNullString(reason)), &d); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *PostgresDriverStore) SetOnDuty(ctx context.Context, id uuid.UUID, onDuty bool) (*Driver, error) {
+	// duty_changed_at only moves on an actual transition so repeated toggles don't reset it.
+	q := `
+		UPDATE drivers
+		SET on_duty = $2,
+			duty_changed_at = CASE WHEN on_duty IS DISTINCT FROM $2 THEN now() ELSE duty_changed_at END
+		WHERE id = $1 AND status = 'approved'
+		RETURNING ` + driverColumns
+	var d Driver
+	if err := scanDriver(s.pool.QueryRow(ctx, q, id, onDuty), &d); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDriverNotApproved
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+var _ DriverStore = (*PostgresDriverStore)(nil)
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdempotencyRecord struct {
+	UserID         uuid.UUID
+	Key            string
+	Method         string
+	Path           string
+	RequestHash    string
+	ResponseStatus *int16
+	ContentType    sql.NullString
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	CompletedAt    sql.NullTime
+	ExpiresAt      time.Time
+}
+
+type IdempotencyStore interface {
+	// Begin claims the key for a new request. If the key is already held (in progress or completed)
+	// the existing record is returned with claimed=false. Expired records, and in-progress claims older
+	// than staleAfter, are taken over as if new.
+	Begin(ctx context.Context, rec *IdempotencyRecord, staleAfter time.Duration) (existing *IdempotencyRecord, claimed bool, err error)
+	Complete(ctx context.Context, userID uuid.UUID, key string, status int, contentType string, body []byte) error
+	// Release drops an in-progress claim so the client may retry, e.g. after a server error.
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type PostgresIdempotencyStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresIdempotencyStore(pool *pgxpool.Pool) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{pool: pool}
+}
+
+const idempotencyColumns = `
+	user_id, idem_key, method, path, request_hash, response_status, content_type, response_body,
+	created_at, completed_at, expires_at`
+
+func scanIdempotency(row pgx.Row, rec *IdempotencyRecord) error {
+	return row.Scan(&rec.UserID, &rec.Key, &rec.Method, &rec.Path, &rec.RequestHash, &rec.ResponseStatus,
+		&rec.ContentType, &rec.ResponseBody, &rec.CreatedAt, &rec.CompletedAt, &rec.ExpiresAt)
+}
+
+func (s *PostgresIdempotencyStore) Begin(ctx context.Context, rec *IdempotencyRecord, staleAfter time.Duration) (*IdempotencyRecord, bool, error) {
+	// Same upsert-with-WHERE shape as driver resubmission: the conflicting row is only overwritten
+	// when it is expired or an abandoned claim, otherwise no row comes back.
+	q := `
+		INSERT INTO idempotency_keys (user_id, idem_key, method, path, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, idem_key) DO UPDATE SET
+			method = EXCLUDED.method,
+			path = EXCLUDED.path,
+			request_hash = EXCLUDED.request_hash,
+			response_status = NULL,
+			content_type = NULL,
+			response_body = NULL,
+			created_at = now(),
+			completed_at = NULL,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < now()
+		   OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.created_at < now() - $7::interval)
+		RETURNING ` + idempotencyColumns
+	var claimed IdempotencyRecord
+	err := scanIdempotency(s.pool.QueryRow(ctx, q,
+		rec.UserID, rec.Key, rec.Method, rec.Path, rec.RequestHash, rec.ExpiresAt, staleAfter,
+	), &claimed)
+	if err == nil {
+		return &claimed, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	var existing IdempotencyRecord
+	if err := scanIdempotency(s.pool.QueryRow(ctx, `
+		SELECT `+idempotencyColumns+`
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idem_key = $2
+	`, rec.UserID, rec.Key), &existing); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Released between our insert attempt and this read; the client can simply retry.
+			return nil, false, ErrNotFound
+		}
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (s *PostgresIdempotencyStore) Complete(ctx context.Context, userID uuid.UUID, key string, status int, contentType string, body []byte) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET response_status = $3, content_type = $4, response_body = $5, completed_at = now()
+		WHERE user_id = $1 AND idem_key = $2 AND completed_at IS NULL
+	`, userID, key, status, ToNullString(contentType), body)
+	return err
+}
+
+func (s *PostgresIdempotencyStore) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	_, err := s.pool.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idem_key = $2 AND completed_at IS NULL
+	`, userID, key)
+	return err
+}
+
+func (s *PostgresIdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+var _ IdempotencyStore = (*PostgresIdempotencyStore)(nil)
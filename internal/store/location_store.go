@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LocationPing is one GPS fix reported by a driver's device. Optional readings are nil when the
+// device did not supply them.
+type LocationPing struct {
+	DriverID   uuid.UUID
+	RecordedAt time.Time
+	Lat        float64
+	Lng        float64
+	Heading    *float32
+	SpeedMPS   *float32
+	AccuracyM  *float32
+	ReceivedAt time.Time
+}
+
+type LocationStore interface {
+	// InsertBatch stores pings, silently skipping any already recorded for the same driver and instant.
+	// It returns how many rows were new.
+	InsertBatch(ctx context.Context, pings []LocationPing) (int64, error)
+	Latest(ctx context.Context, driverID uuid.UUID) (*LocationPing, error)
+	// Breadcrumbs returns a driver's pings in [from, to) in recorded order, capped at limit.
+	Breadcrumbs(ctx context.Context, driverID uuid.UUID, from, to time.Time, limit int) ([]LocationPing, error)
+	// EnsurePartitions creates the monthly partitions covering from and the following months.
+	EnsurePartitions(ctx context.Context, from time.Time, months int) error
+	// DefaultPartitionRows counts pings sitting in the default partition, i.e. written before their
+	// month's partition existed.
+	DefaultPartitionRows(ctx context.Context) (int64, error)
+}
+
+type PostgresLocationStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresLocationStore(pool *pgxpool.Pool) *PostgresLocationStore {
+	return &PostgresLocationStore{pool: pool}
+}
+
+const locationColumns = `driver_id, recorded_at, lat, lng, heading, speed_mps, accuracy_m, received_at`
+
+func scanLocation(row pgx.Row, p *LocationPing) error {
+	return row.Scan(&p.DriverID, &p.RecordedAt, &p.Lat, &p.Lng, &p.Heading, &p.SpeedMPS, &p.AccuracyM, &p.ReceivedAt)
+}
+
+func (s *PostgresLocationStore) InsertBatch(ctx context.Context, pings []LocationPing) (int64, error) {
+	if len(pings) == 0 {
+		return 0, nil
+	}
+	batch := &pgx.Batch{}
+	for _, p := range pings {
+		batch.Queue(`
+			INSERT INTO driver_locations (driver_id, recorded_at, lat, lng, heading, speed_mps, accuracy_m)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (driver_id, recorded_at) DO NOTHING
+		`, p.DriverID, p.RecordedAt.UTC(), p.Lat, p.Lng, p.Heading, p.SpeedMPS, p.AccuracyM)
+	}
+
+	br := s.pool.SendBatch(ctx, batch)
+	defer func() { _ = br.Close() }()
+
+	var inserted int64
+	for range pings {
+		tag, err := br.Exec()
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return inserted, ErrNotFound
+			}
+			return inserted, err
+		}
+		inserted += tag.RowsAffected()
+	}
+	return inserted, br.Close()
+}
+
+func (s *PostgresLocationStore) Latest(ctx context.Context, driverID uuid.UUID) (*LocationPing, error) {
+	q := `
+		SELECT ` + locationColumns + `
+		FROM driver_locations
+		WHERE driver_id = $1
+		ORDER BY recorded_at DESC
+		LIMIT 1;
+	`
+	var p LocationPing
+	if err := scanLocation(s.pool.QueryRow(ctx, q, driverID), &p); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PostgresLocationStore) Breadcrumbs(ctx context.Context, driverID uuid.UUID, from, to time.Time, limit int) ([]LocationPing, error) {
+	q := `
+		SELECT ` + locationColumns + `
+		FROM driver_locations
+		WHERE driver_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+		ORDER BY recorded_at
+		LIMIT $4;
+	`
+	rows, err := s.pool.Query(ctx, q, driverID, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]LocationPing, 0)
+	for rows.Next() {
+		var p LocationPing
+		if err := scanLocation(rows, &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresLocationStore) EnsurePartitions(ctx context.Context, from time.Time, months int) error {
+	from = from.UTC()
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < months; i++ {
+		if _, err := s.pool.Exec(ctx, `SELECT ensure_driver_locations_partition($1)`, start.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PostgresLocationStore) DefaultPartitionRows(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.pool.QueryRow(ctx, `SELECT count(*) FROM driver_locations_default`).Scan(&n)
+	return n, err
+}
+
+var _ LocationStore = (*PostgresLocationStore)(nil)
@@ -0,0 +1,433 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	MaintenanceDueOK      = "ok"
+	MaintenanceDue        = "due"
+	MaintenanceDueOverdue = "overdue"
+
+	MaintenanceScheduled  = "scheduled"
+	MaintenanceInProgress = "in_progress"
+	MaintenanceCompleted  = "completed"
+	MaintenanceCancelled  = "cancelled"
+)
+
+var (
+	ErrDuplicateSchedule       = errors.New("vehicle already has a schedule of this kind")
+	ErrMaintenanceInvalidState = errors.New("maintenance record is not in a valid state for this action")
+)
+
+type MaintenanceSchedule struct {
+	ID                 uuid.UUID
+	VehicleID          uuid.UUID
+	Kind               string
+	IntervalDays       *int32
+	IntervalMiles      *int32
+	LastPerformedOn    time.Time
+	LastPerformedMiles int
+	NextDueOn          sql.NullTime
+	NextDueMiles       *int32
+	DueState           string
+	CheckedAt          sql.NullTime
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+type MaintenanceRecord struct {
+	ID            uuid.UUID
+	VehicleID     uuid.UUID
+	ScheduleID    *uuid.UUID
+	Kind          string
+	Description   sql.NullString
+	Vendor        sql.NullString
+	Status        string
+	ScheduledFor  time.Time
+	StartedAt     sql.NullTime
+	CompletedAt   sql.NullTime
+	OdometerMiles *int32
+	CostCents     *int64
+	Notes         sql.NullString
+	CreatedBy     *uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// DueThresholds controls how early a schedule is flagged as due before it becomes overdue.
+type DueThresholds struct {
+	Days  int
+	Miles int
+}
+
+type MaintenanceStore interface {
+	CreateSchedule(ctx context.Context, sch *MaintenanceSchedule) (*MaintenanceSchedule, error)
+	ListSchedules(ctx context.Context, vehicleID uuid.UUID) ([]MaintenanceSchedule, error)
+	DeleteSchedule(ctx context.Context, vehicleID, scheduleID uuid.UUID) error
+	// RefreshDueStates recomputes due_state for every schedule on a non-retired vehicle. Only schedules
+	// whose state changed are written; it returns the ones among them that became due or overdue.
+	RefreshDueStates(ctx context.Context, today time.Time, th DueThresholds) ([]MaintenanceSchedule, error)
+	ListDue(ctx context.Context, limit, offset int) ([]MaintenanceSchedule, error)
+
+	CreateRecord(ctx context.Context, rec *MaintenanceRecord) (*MaintenanceRecord, error)
+	ListRecords(ctx context.Context, vehicleID uuid.UUID, limit, offset int) ([]MaintenanceRecord, error)
+	// StartRecord moves a scheduled record in progress, taking the vehicle out of service and ending its assignment.
+	StartRecord(ctx context.Context, vehicleID, recordID, by uuid.UUID) (*MaintenanceRecord, error)
+	// CompleteRecord closes the record, advances its schedule and odometer, and returns the vehicle to service
+	// if this record was what took it out.
+	CompleteRecord(ctx context.Context, vehicleID, recordID uuid.UUID, odometer *int, costCents *int64, notes string) (*MaintenanceRecord, error)
+	CancelRecord(ctx context.Context, vehicleID, recordID uuid.UUID) (*MaintenanceRecord, error)
+}
+
+type PostgresMaintenanceStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresMaintenanceStore(pool *pgxpool.Pool) *PostgresMaintenanceStore {
+	return &PostgresMaintenanceStore{pool: pool}
+}
+
+const scheduleColumns = `
+	id, vehicle_id, kind, interval_days, interval_miles, last_performed_on, last_performed_miles,
+	next_due_on, next_due_miles, due_state, checked_at, created_at, updated_at`
+
+func scanSchedule(row pgx.Row, sch *MaintenanceSchedule) error {
+	var lastMiles int32
+	if err := row.Scan(
+		&sch.ID, &sch.VehicleID, &sch.Kind, &sch.IntervalDays, &sch.IntervalMiles, &sch.LastPerformedOn, &lastMiles,
+		&sch.NextDueOn, &sch.NextDueMiles, &sch.DueState, &sch.CheckedAt, &sch.CreatedAt, &sch.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	sch.LastPerformedMiles = int(lastMiles)
+	return nil
+}
+
+func collectSchedules(rows pgx.Rows) ([]MaintenanceSchedule, error) {
+	defer rows.Close()
+	out := make([]MaintenanceSchedule, 0)
+	for rows.Next() {
+		var sch MaintenanceSchedule
+		if err := scanSchedule(rows, &sch); err != nil {
+			return nil, err
+		}
+		out = append(out, sch)
+	}
+	return out, rows.Err()
+}
+
+const recordColumns = `
+	id, vehicle_id, schedule_id, kind, description, vendor, status, scheduled_for, started_at, completed_at,
+	odometer_miles, cost_cents, notes, created_by, created_at, updated_at`
+
+func scanRecord(row pgx.Row, rec *MaintenanceRecord) error {
+	return row.Scan(
+		&rec.ID, &rec.VehicleID, &rec.ScheduleID, &rec.Kind, &rec.Description, &rec.Vendor, &rec.Status,
+		&rec.ScheduledFor, &rec.StartedAt, &rec.CompletedAt, &rec.OdometerMiles, &rec.CostCents, &rec.Notes,
+		&rec.CreatedBy, &rec.CreatedAt, &rec.UpdatedAt,
+	)
+}
+
+func (s *PostgresMaintenanceStore) CreateSchedule(ctx context.Context, sch *MaintenanceSchedule) (*MaintenanceSchedule, error) {
+	q := `
+		INSERT INTO maintenance_schedules
+			(vehicle_id, kind, interval_days, interval_miles, last_performed_on, last_performed_miles)
+		VALUES ($1, lower(btrim($2)), $3, $4, $5, $6)
+		RETURNING ` + scheduleColumns
+	var out MaintenanceSchedule
+	if err := scanSchedule(s.pool.QueryRow(ctx, q,
+		sch.VehicleID, sch.Kind, sch.IntervalDays, sch.IntervalMiles, sch.LastPerformedOn, sch.LastPerformedMiles,
+	), &out); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateSchedule
+		}
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresMaintenanceStore) ListSchedules(ctx context.Context, vehicleID uuid.UUID) ([]MaintenanceSchedule, error) {
+	q := `
+		SELECT ` + scheduleColumns + `
+		FROM maintenance_schedules
+		WHERE vehicle_id = $1
+		ORDER BY kind;
+	`
+	rows, err := s.pool.Query(ctx, q, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	return collectSchedules(rows)
+}
+
+func (s *PostgresMaintenanceStore) DeleteSchedule(ctx context.Context, vehicleID, scheduleID uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM maintenance_schedules WHERE id = $1 AND vehicle_id = $2`, scheduleID, vehicleID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresMaintenanceStore) RefreshDueStates(ctx context.Context, today time.Time, th DueThresholds) ([]MaintenanceSchedule, error) {
+	q := `
+		WITH computed AS (
+			SELECT s.id,
+				CASE
+					WHEN s.next_due_on < $1::date OR v.odometer_miles >= s.next_due_miles THEN 'overdue'
+					WHEN s.next_due_on <= $1::date + $2::int OR v.odometer_miles >= s.next_due_miles - $3::int THEN 'due'
+					ELSE 'ok'
+				END::maintenance_due_state AS state
+			FROM maintenance_schedules s
+			JOIN vehicles v ON v.id = s.vehicle_id
+			WHERE v.status <> 'retired'
+		)
+		UPDATE maintenance_schedules s
+		SET due_state = c.state, checked_at = now()
+		FROM computed c
+		WHERE s.id = c.id AND (s.due_state IS DISTINCT FROM c.state OR s.checked_at IS NULL)
+		RETURNING ` + prefixColumns("s", scheduleColumns)
+	rows, err := s.pool.Query(ctx, q, today, th.Days, th.Miles)
+	if err != nil {
+		return nil, err
+	}
+	all, err := collectSchedules(rows)
+	if err != nil {
+		return nil, err
+	}
+	flagged := make([]MaintenanceSchedule, 0)
+	for _, sch := range all {
+		if sch.DueState != MaintenanceDueOK {
+			flagged = append(flagged, sch)
+		}
+	}
+	return flagged, nil
+}
+
+func (s *PostgresMaintenanceStore) ListDue(ctx context.Context, limit, offset int) ([]MaintenanceSchedule, error) {
+	q := `
+		SELECT ` + scheduleColumns + `
+		FROM maintenance_schedules
+		WHERE due_state <> 'ok'
+		ORDER BY due_state DESC, next_due_on ASC NULLS LAST
+		LIMIT $1 OFFSET $2;
+	`
+	rows, err := s.pool.Query(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectSchedules(rows)
+}
+
+func (s *PostgresMaintenanceStore) CreateRecord(ctx context.Context, rec *MaintenanceRecord) (*MaintenanceRecord, error) {
+	q := `
+		INSERT INTO maintenance_records
+			(vehicle_id, schedule_id, kind, description, vendor, scheduled_for, created_by)
+		SELECT $1, $2, lower(btrim($3)), $4, $5, $6, $7
+		WHERE $2::uuid IS NULL
+		   OR EXISTS (SELECT 1 FROM maintenance_schedules WHERE id = $2 AND vehicle_id = $1)
+		RETURNING ` + recordColumns
+	var out MaintenanceRecord
+	if err := scanRecord(s.pool.QueryRow(ctx, q,
+		rec.VehicleID, rec.ScheduleID, rec.Kind, rec.Description, rec.Vendor, rec.ScheduledFor, rec.CreatedBy,
+	), &out); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresMaintenanceStore) ListRecords(ctx context.Context, vehicleID uuid.UUID, limit, offset int) ([]MaintenanceRecord, error) {
+	q := `
+		SELECT ` + recordColumns + `
+		FROM maintenance_records
+		WHERE vehicle_id = $1
+		ORDER BY scheduled_for DESC, created_at DESC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := s.pool.Query(ctx, q, vehicleID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]MaintenanceRecord, 0)
+	for rows.Next() {
+		var rec MaintenanceRecord
+		if err := scanRecord(rows, &rec); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// lockRecord locks a record row and checks that its status is one of the allowed ones.
+func lockRecord(ctx context.Context, tx pgx.Tx, vehicleID, recordID uuid.UUID, allowed ...string) (status string, scheduleID *uuid.UUID, tookOut bool, err error) {
+	if err = tx.QueryRow(ctx, `
+		SELECT status, schedule_id, took_out_of_service
+		FROM maintenance_records
+		WHERE id = $1 AND vehicle_id = $2
+		FOR UPDATE
+	`, recordID, vehicleID).Scan(&status, &scheduleID, &tookOut); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, false, ErrNotFound
+		}
+		return "", nil, false, err
+	}
+	for _, a := range allowed {
+		if status == a {
+			return status, scheduleID, tookOut, nil
+		}
+	}
+	return "", nil, false, ErrMaintenanceInvalidState
+}
+
+func (s *PostgresMaintenanceStore) StartRecord(ctx context.Context, vehicleID, recordID, by uuid.UUID) (*MaintenanceRecord, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, _, _, err := lockRecord(ctx, tx, vehicleID, recordID, MaintenanceScheduled); err != nil {
+		return nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE vehicles SET status = 'out_of_service' WHERE id = $1 AND status = 'active'`, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE vehicle_assignments SET unassigned_at = now(), unassigned_by = $2
+		WHERE vehicle_id = $1 AND unassigned_at IS NULL
+	`, vehicleID, by); err != nil {
+		return nil, err
+	}
+
+	var rec MaintenanceRecord
+	if err := scanRecord(tx.QueryRow(ctx, `
+		UPDATE maintenance_records
+		SET status = 'in_progress', started_at = now(), took_out_of_service = $2
+		WHERE id = $1
+		RETURNING `+recordColumns, recordID, tag.RowsAffected() > 0), &rec); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *PostgresMaintenanceStore) CompleteRecord(ctx context.Context, vehicleID, recordID uuid.UUID, odometer *int, costCents *int64, notes string) (*MaintenanceRecord, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, scheduleID, tookOut, err := lockRecord(ctx, tx, vehicleID, recordID, MaintenanceScheduled, MaintenanceInProgress)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec MaintenanceRecord
+	if err := scanRecord(tx.QueryRow(ctx, `
+		UPDATE maintenance_records
+		SET status = 'completed', completed_at = now(), started_at = COALESCE(started_at, now()),
+			odometer_miles = $2, cost_cents = $3, notes = $4
+		WHERE id = $1
+		RETURNING `+recordColumns, recordID, odometer, costCents, ToNullString(notes)), &rec); err != nil {
+		return nil, err
+	}
+
+	if odometer != nil {
+		if _, err := tx.Exec(ctx, `
+			UPDATE vehicles SET odometer_miles = $2, odometer_updated_at = now()
+			WHERE id = $1 AND odometer_miles < $2
+		`, vehicleID, *odometer); err != nil {
+			return nil, err
+		}
+	}
+
+	if scheduleID != nil {
+		if _, err := tx.Exec(ctx, `
+			UPDATE maintenance_schedules
+			SET last_performed_on = current_date,
+				last_performed_miles = COALESCE($2, (SELECT odometer_miles FROM vehicles WHERE id = vehicle_id)),
+				due_state = 'ok'
+			WHERE id = $1
+		`, *scheduleID, odometer); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only undo our own out-of-service change, and only once no other record is still holding the vehicle.
+	if tookOut {
+		if _, err := tx.Exec(ctx, `
+			UPDATE vehicles SET status = 'active'
+			WHERE id = $1 AND status = 'out_of_service'
+			  AND NOT EXISTS (
+				SELECT 1 FROM maintenance_records
+				WHERE vehicle_id = $1 AND status = 'in_progress' AND took_out_of_service AND id <> $2
+			  )
+		`, vehicleID, recordID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *PostgresMaintenanceStore) CancelRecord(ctx context.Context, vehicleID, recordID uuid.UUID) (*MaintenanceRecord, error) {
+	q := `
+		UPDATE maintenance_records SET status = 'cancelled'
+		WHERE id = $1 AND vehicle_id = $2 AND status = 'scheduled'
+		RETURNING ` + recordColumns
+	var rec MaintenanceRecord
+	if err := scanRecord(s.pool.QueryRow(ctx, q, recordID, vehicleID), &rec); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			var exists bool
+			if err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM maintenance_records WHERE id = $1 AND vehicle_id = $2)`, recordID, vehicleID).Scan(&exists); err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, ErrNotFound
+			}
+			return nil, ErrMaintenanceInvalidState
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// prefixColumns qualifies a comma-separated column list with a table alias.
+func prefixColumns(alias, cols string) string {
+	parts := strings.Split(cols, ",")
+	for i, p := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ", ")
+}
+
+var _ MaintenanceStore = (*PostgresMaintenanceStore)(nil)
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PaymentEvent struct {
+	ID          uuid.UUID
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     []byte
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
+	Attempts    int
+	LastError   sql.NullString
+}
+
+type PaymentEventFilter struct {
+	Provider string
+	// UnprocessedOnly limits the list to events no handler has applied yet.
+	UnprocessedOnly bool
+	Since           time.Time
+	Limit           int
+}
+
+type PaymentEventStore interface {
+	// Record stores a raw event once per (provider, event id). When the event was already received the
+	// stored row is returned with inserted=false.
+	Record(ctx context.Context, e *PaymentEvent) (stored *PaymentEvent, inserted bool, err error)
+	Get(ctx context.Context, id uuid.UUID) (*PaymentEvent, error)
+	List(ctx context.Context, f PaymentEventFilter) ([]PaymentEvent, error)
+	// MarkResult counts a processing attempt; a nil procErr marks the event processed.
+	MarkResult(ctx context.Context, id uuid.UUID, procErr error) error
+}
+
+type PostgresPaymentEventStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresPaymentEventStore(pool *pgxpool.Pool) *PostgresPaymentEventStore {
+	return &PostgresPaymentEventStore{pool: pool}
+}
+
+const paymentEventColumns = `
+	id, provider, event_id, event_type, payload, received_at, processed_at, attempts, last_error`
+
+func scanPaymentEvent(row pgx.Row, e *PaymentEvent) error {
+	return row.Scan(&e.ID, &e.Provider, &e.EventID, &e.EventType, &e.Payload, &e.ReceivedAt, &e.ProcessedAt,
+		&e.Attempts, &e.LastError)
+}
+
+func (s *PostgresPaymentEventStore) Record(ctx context.Context, e *PaymentEvent) (*PaymentEvent, bool, error) {
+	q := `
+		INSERT INTO payment_events (provider, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4::jsonb)
+		ON CONFLICT (provider, event_id) DO NOTHING
+		RETURNING ` + paymentEventColumns
+	var out PaymentEvent
+	err := scanPaymentEvent(s.pool.QueryRow(ctx, q, e.Provider, e.EventID, e.EventType, string(e.Payload)), &out)
+	if err == nil {
+		return &out, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	if err := scanPaymentEvent(s.pool.QueryRow(ctx, `
+		SELECT `+paymentEventColumns+`
+		FROM payment_events
+		WHERE provider = $1 AND event_id = $2
+	`, e.Provider, e.EventID), &out); err != nil {
+		return nil, false, err
+	}
+	return &out, false, nil
+}
+
+func (s *PostgresPaymentEventStore) Get(ctx context.Context, id uuid.UUID) (*PaymentEvent, error) {
+	q := `SELECT ` + paymentEventColumns + ` FROM payment_events WHERE id = $1 LIMIT 1;`
+	var e PaymentEvent
+	if err := scanPaymentEvent(s.pool.QueryRow(ctx, q, id), &e); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *PostgresPaymentEventStore) List(ctx context.Context, f PaymentEventFilter) ([]PaymentEvent, error) {
+	q := `
+		SELECT ` + paymentEventColumns + `
+		FROM payment_events
+		WHERE ($1 = '' OR provider = $1)
+		  AND (NOT $2 OR processed_at IS NULL)
+		  AND received_at >= $3
+		ORDER BY received_at ASC
+		LIMIT $4;
+	`
+	rows, err := s.pool.Query(ctx, q, f.Provider, f.UnprocessedOnly, f.Since, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PaymentEvent, 0)
+	for rows.Next() {
+		var e PaymentEvent
+		if err := scanPaymentEvent(rows, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresPaymentEventStore) MarkResult(ctx context.Context, id uuid.UUID, procErr error) error {
+	var lastError sql.NullString
+	if procErr != nil {
+		lastError = sql.NullString{String: procErr.Error(), Valid: true}
+	}
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE payment_events SET
+			attempts = attempts + 1,
+			last_error = $2,
+			processed_at = CASE WHEN $2::text IS NULL THEN now() ELSE processed_at END
+		WHERE id = $1
+	`, id, lastError)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+var _ PaymentEventStore = (*PostgresPaymentEventStore)(nil)
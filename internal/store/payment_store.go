@@ -0,0 +1,392 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	PaymentIntentRequiresCapture = "requires_capture"
+	PaymentIntentSucceeded       = "succeeded"
+	PaymentIntentCanceled        = "canceled"
+	PaymentIntentFailed          = "failed"
+)
+
+var ErrDuplicatePaymentMethod = errors.New("payment method already saved")
+
+type PaymentMethod struct {
+	ID                 uuid.UUID
+	UserID             uuid.UUID
+	Provider           string
+	ProviderCustomerID string
+	ProviderMethodID   string
+	Brand              string
+	Last4              string
+	ExpMonth           int16
+	ExpYear            int16
+	IsDefault          bool
+	CreatedAt          time.Time
+}
+
+type PaymentIntent struct {
+	ID                    uuid.UUID
+	UserID                uuid.UUID
+	BookingID             *uuid.UUID
+	PaymentMethodID       uuid.UUID
+	Provider              string
+	ProviderIntentID      sql.NullString
+	Status                string
+	Currency              string
+	AmountAuthorizedCents int64
+	AmountCapturedCents   int64
+	AmountRefundedCents   int64
+	FailureReason         sql.NullString
+	DisputedAt            sql.NullTime
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+type PaymentStore interface {
+	// CustomerID returns the provider customer already created for the user, or ErrNotFound.
+	CustomerID(ctx context.Context, userID uuid.UUID, provider string) (string, error)
+	// SaveMethod stores a card; the user's first active card becomes the default.
+	SaveMethod(ctx context.Context, m *PaymentMethod) (*PaymentMethod, error)
+	ListMethods(ctx context.Context, userID uuid.UUID) ([]PaymentMethod, error)
+	GetMethod(ctx context.Context, userID, id uuid.UUID) (*PaymentMethod, error)
+	SetDefaultMethod(ctx context.Context, userID, id uuid.UUID) (*PaymentMethod, error)
+	// RemoveMethod soft-deletes a card and promotes the newest remaining card if it was the default.
+	RemoveMethod(ctx context.Context, userID, id uuid.UUID) error
+
+	CreateIntent(ctx context.Context, pi *PaymentIntent) (*PaymentIntent, error)
+	GetIntent(ctx context.Context, id uuid.UUID) (*PaymentIntent, error)
+	GetIntentByProviderID(ctx context.Context, provider, providerIntentID string) (*PaymentIntent, error)
+	ListIntentsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]PaymentIntent, error)
+	// UpdateIntent locks the intent, lets fn change its status, amounts and failure reason, and saves the
+	// result, so concurrent updates from webhooks and adjustments can't overwrite each other. An error
+	// from fn aborts the update and is returned as is.
+	UpdateIntent(ctx context.Context, id uuid.UUID, fn func(pi *PaymentIntent) error) (*PaymentIntent, error)
+	// MarkIntentDisputed records the first dispute opened against the intent; later calls keep the original time.
+	MarkIntentDisputed(ctx context.Context, id uuid.UUID, at time.Time) (*PaymentIntent, error)
+}
+
+type PostgresPaymentStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresPaymentStore(pool *pgxpool.Pool) *PostgresPaymentStore {
+	return &PostgresPaymentStore{pool: pool}
+}
+
+const paymentMethodColumns = `
+	id, user_id, provider, provider_customer_id, provider_method_id, brand, last4, exp_month, exp_year,
+	is_default, created_at`
+
+func scanPaymentMethod(row pgx.Row, m *PaymentMethod) error {
+	return row.Scan(&m.ID, &m.UserID, &m.Provider, &m.ProviderCustomerID, &m.ProviderMethodID, &m.Brand, &m.Last4,
+		&m.ExpMonth, &m.ExpYear, &m.IsDefault, &m.CreatedAt)
+}
+
+const paymentIntentColumns = `
+	id, user_id, booking_id, payment_method_id, provider, provider_intent_id, status, currency,
+	amount_authorized_cents, amount_captured_cents, amount_refunded_cents, failure_reason, disputed_at, created_at, updated_at`
+
+func scanPaymentIntent(row pgx.Row, pi *PaymentIntent) error {
+	return row.Scan(&pi.ID, &pi.UserID, &pi.BookingID, &pi.PaymentMethodID, &pi.Provider, &pi.ProviderIntentID,
+		&pi.Status, &pi.Currency, &pi.AmountAuthorizedCents, &pi.AmountCapturedCents, &pi.AmountRefundedCents,
+		&pi.FailureReason, &pi.DisputedAt, &pi.CreatedAt, &pi.UpdatedAt)
+}
+
+func (s *PostgresPaymentStore) CustomerID(ctx context.Context, userID uuid.UUID, provider string) (string, error) {
+	var id string
+	err := s.pool.QueryRow(ctx, `
+		SELECT provider_customer_id
+		FROM payment_methods
+		WHERE user_id = $1 AND provider = $2
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID, provider).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+func (s *PostgresPaymentStore) SaveMethod(ctx context.Context, m *PaymentMethod) (*PaymentMethod, error) {
+	q := `
+		INSERT INTO payment_methods
+			(user_id, provider, provider_customer_id, provider_method_id, brand, last4, exp_month, exp_year, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+			$9 AND NOT EXISTS (SELECT 1 FROM payment_methods WHERE user_id = $1 AND is_default AND removed_at IS NULL))
+		RETURNING ` + paymentMethodColumns
+	insert := func(asDefault bool) (*PaymentMethod, error) {
+		var out PaymentMethod
+		err := scanPaymentMethod(s.pool.QueryRow(ctx, q,
+			m.UserID, m.Provider, m.ProviderCustomerID, m.ProviderMethodID, m.Brand, m.Last4, m.ExpMonth, m.ExpYear, asDefault,
+		), &out)
+		return &out, err
+	}
+
+	out, err := insert(true)
+	if isUniqueViolationOf(err, "payment_methods_one_default") {
+		// A concurrent save made its card the default first; keep this one as a plain saved card.
+		out, err = insert(false)
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicatePaymentMethod
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *PostgresPaymentStore) ListMethods(ctx context.Context, userID uuid.UUID) ([]PaymentMethod, error) {
+	q := `
+		SELECT ` + paymentMethodColumns + `
+		FROM payment_methods
+		WHERE user_id = $1 AND removed_at IS NULL
+		ORDER BY is_default DESC, created_at DESC;
+	`
+	rows, err := s.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PaymentMethod, 0)
+	for rows.Next() {
+		var m PaymentMethod
+		if err := scanPaymentMethod(rows, &m); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresPaymentStore) GetMethod(ctx context.Context, userID, id uuid.UUID) (*PaymentMethod, error) {
+	q := `
+		SELECT ` + paymentMethodColumns + `
+		FROM payment_methods
+		WHERE id = $1 AND user_id = $2 AND removed_at IS NULL
+		LIMIT 1;
+	`
+	var m PaymentMethod
+	if err := scanPaymentMethod(s.pool.QueryRow(ctx, q, id, userID), &m); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *PostgresPaymentStore) SetDefaultMethod(ctx context.Context, userID, id uuid.UUID) (*PaymentMethod, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Clear first: the partial unique index allows only one default per user at any moment.
+	if _, err := tx.Exec(ctx, `
+		UPDATE payment_methods SET is_default = false
+		WHERE user_id = $1 AND is_default AND id <> $2
+	`, userID, id); err != nil {
+		return nil, err
+	}
+
+	var m PaymentMethod
+	if err := scanPaymentMethod(tx.QueryRow(ctx, `
+		UPDATE payment_methods SET is_default = true
+		WHERE id = $1 AND user_id = $2 AND removed_at IS NULL
+		RETURNING `+paymentMethodColumns, id, userID), &m); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *PostgresPaymentStore) RemoveMethod(ctx context.Context, userID, id uuid.UUID) error {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var wasDefault bool
+	if err := tx.QueryRow(ctx, `
+		SELECT is_default FROM payment_methods
+		WHERE id = $1 AND user_id = $2 AND removed_at IS NULL
+		FOR UPDATE
+	`, id, userID).Scan(&wasDefault); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE payment_methods SET removed_at = now(), is_default = false WHERE id = $1
+	`, id); err != nil {
+		return err
+	}
+
+	if wasDefault {
+		if _, err := tx.Exec(ctx, `
+			UPDATE payment_methods SET is_default = true
+			WHERE id = (
+				SELECT id FROM payment_methods
+				WHERE user_id = $1 AND removed_at IS NULL
+				ORDER BY created_at DESC
+				LIMIT 1
+			)
+		`, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresPaymentStore) CreateIntent(ctx context.Context, pi *PaymentIntent) (*PaymentIntent, error) {
+	q := `
+		INSERT INTO payment_intents
+			(user_id, booking_id, payment_method_id, provider, provider_intent_id, status, currency,
+			 amount_authorized_cents, amount_captured_cents, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + paymentIntentColumns
+	var out PaymentIntent
+	if err := scanPaymentIntent(s.pool.QueryRow(ctx, q,
+		pi.UserID, pi.BookingID, pi.PaymentMethodID, pi.Provider, pi.ProviderIntentID, pi.Status, pi.Currency,
+		pi.AmountAuthorizedCents, pi.AmountCapturedCents, pi.FailureReason,
+	), &out); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresPaymentStore) GetIntent(ctx context.Context, id uuid.UUID) (*PaymentIntent, error) {
+	q := `SELECT ` + paymentIntentColumns + ` FROM payment_intents WHERE id = $1 LIMIT 1;`
+	var pi PaymentIntent
+	if err := scanPaymentIntent(s.pool.QueryRow(ctx, q, id), &pi); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &pi, nil
+}
+
+func (s *PostgresPaymentStore) GetIntentByProviderID(ctx context.Context, provider, providerIntentID string) (*PaymentIntent, error) {
+	q := `SELECT ` + paymentIntentColumns + ` FROM payment_intents WHERE provider = $1 AND provider_intent_id = $2 LIMIT 1;`
+	var pi PaymentIntent
+	if err := scanPaymentIntent(s.pool.QueryRow(ctx, q, provider, providerIntentID), &pi); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &pi, nil
+}
+
+func (s *PostgresPaymentStore) ListIntentsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]PaymentIntent, error) {
+	q := `
+		SELECT ` + paymentIntentColumns + `
+		FROM payment_intents
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := s.pool.Query(ctx, q, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PaymentIntent, 0)
+	for rows.Next() {
+		var pi PaymentIntent
+		if err := scanPaymentIntent(rows, &pi); err != nil {
+			return nil, err
+		}
+		out = append(out, pi)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresPaymentStore) UpdateIntent(ctx context.Context, id uuid.UUID, fn func(pi *PaymentIntent) error) (*PaymentIntent, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var pi PaymentIntent
+	if err := scanPaymentIntent(tx.QueryRow(ctx, `
+		SELECT `+paymentIntentColumns+` FROM payment_intents WHERE id = $1 FOR UPDATE
+	`, id), &pi); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := fn(&pi); err != nil {
+		return nil, err
+	}
+
+	q := `
+		UPDATE payment_intents SET
+			provider_intent_id = COALESCE($2, provider_intent_id),
+			status = $3,
+			amount_captured_cents = $4,
+			amount_refunded_cents = $5,
+			failure_reason = $6
+		WHERE id = $1
+		RETURNING ` + paymentIntentColumns
+	var out PaymentIntent
+	if err := scanPaymentIntent(tx.QueryRow(ctx, q,
+		id, pi.ProviderIntentID, pi.Status, pi.AmountCapturedCents, pi.AmountRefundedCents, pi.FailureReason,
+	), &out); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresPaymentStore) MarkIntentDisputed(ctx context.Context, id uuid.UUID, at time.Time) (*PaymentIntent, error) {
+	q := `
+		UPDATE payment_intents SET disputed_at = COALESCE(disputed_at, $2)
+		WHERE id = $1
+		RETURNING ` + paymentIntentColumns
+	var out PaymentIntent
+	if err := scanPaymentIntent(s.pool.QueryRow(ctx, q, id, at), &out); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+var _ PaymentStore = (*PostgresPaymentStore)(nil)
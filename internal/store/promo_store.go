@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/promo"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	PromoKindPercentage = promo.KindPercentage
+	PromoKindFixed      = promo.KindFixed
+)
+
+var (
+	ErrDuplicatePromoCode = errors.New("promo code already exists")
+	ErrPromoInactive      = errors.New("promo code is not active")
+	ErrPromoExhausted     = errors.New("promo code has reached its redemption limit")
+	ErrPromoUserLimit     = errors.New("promo code already used the maximum number of times by this user")
+	ErrRedemptionReversed = errors.New("redemption was already reversed")
+)
+
+type PromoCode struct {
+	ID          uuid.UUID
+	Code        string
+	Description sql.NullString
+	promo.Rules
+	StartsAt        time.Time
+	EndsAt          *time.Time
+	MaxRedemptions  *int
+	MaxPerUser      int
+	RedemptionCount int
+	Active          bool
+	CreatedBy       *uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// Live reports whether the code is switched on and inside its validity window at t.
+func (p *PromoCode) Live(t time.Time) bool {
+	return p.Active && !t.Before(p.StartsAt) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+type PromoRedemption struct {
+	ID            uuid.UUID
+	PromoID       uuid.UUID
+	UserID        uuid.UUID
+	BookingID     *uuid.UUID
+	FareCents     int64
+	DiscountCents int64
+	Status        string
+	CreatedAt     time.Time
+	ReversedAt    sql.NullTime
+}
+
+type PromoStore interface {
+	Create(ctx context.Context, p *PromoCode) (*PromoCode, error)
+	List(ctx context.Context, limit, offset int) ([]PromoCode, error)
+	GetByCode(ctx context.Context, code string) (*PromoCode, error)
+	SetActive(ctx context.Context, id uuid.UUID, active bool) (*PromoCode, error)
+	// UserRedemptions counts the user's applied (not reversed) redemptions of the promo.
+	UserRedemptions(ctx context.Context, promoID, userID uuid.UUID) (int, error)
+	// HasPaidRide reports whether the user has any captured payment, which is what "first ride" promos
+	// check against until bookings exist.
+	HasPaidRide(ctx context.Context, userID uuid.UUID) (bool, error)
+	// Redeem records a redemption of the code for trip under a lock on the promo row. It re-checks that
+	// the code is live, that neither the global nor the per-user limit would be exceeded, and that the
+	// trip meets the code's rules, then computes the discount itself; the caller's FareCents and
+	// DiscountCents are ignored. Whether the trip is a first ride is decided here too.
+	Redeem(ctx context.Context, r *PromoRedemption, trip promo.Trip) (*PromoRedemption, error)
+	// Reverse releases a redemption, e.g. when the booking it was used on is cancelled.
+	Reverse(ctx context.Context, redemptionID uuid.UUID) (*PromoRedemption, error)
+}
+
+type PostgresPromoStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresPromoStore(pool *pgxpool.Pool) *PostgresPromoStore {
+	return &PostgresPromoStore{pool: pool}
+}
+
+const promoColumns = `
+	id, code, description, kind, percent_bps, amount_cents, max_discount_cents, min_fare_cents, vehicle_classes,
+	zone_ids, first_ride_only, starts_at, ends_at, max_redemptions, max_per_user, redemption_count, active,
+	created_by, created_at, updated_at`
+
+func scanPromo(row pgx.Row, p *PromoCode) error {
+	return row.Scan(&p.ID, &p.Code, &p.Description, &p.Kind, &p.PercentBps, &p.AmountCents, &p.MaxDiscountCents,
+		&p.MinFareCents, &p.VehicleClasses, &p.ZoneIDs, &p.FirstRideOnly, &p.StartsAt, &p.EndsAt, &p.MaxRedemptions,
+		&p.MaxPerUser, &p.RedemptionCount, &p.Active, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+}
+
+const redemptionColumns = `
+	id, promo_id, user_id, booking_id, fare_cents, discount_cents, status, created_at, reversed_at`
+
+func scanRedemption(row pgx.Row, r *PromoRedemption) error {
+	return row.Scan(&r.ID, &r.PromoID, &r.UserID, &r.BookingID, &r.FareCents, &r.DiscountCents, &r.Status,
+		&r.CreatedAt, &r.ReversedAt)
+}
+
+func (s *PostgresPromoStore) Create(ctx context.Context, p *PromoCode) (*PromoCode, error) {
+	q := `
+		INSERT INTO promo_codes
+			(code, description, kind, percent_bps, amount_cents, max_discount_cents, min_fare_cents, vehicle_classes,
+			 zone_ids, first_ride_only, starts_at, ends_at, max_redemptions, max_per_user, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING ` + promoColumns
+	var out PromoCode
+	if err := scanPromo(s.pool.QueryRow(ctx, q,
+		p.Code, p.Description, p.Kind, p.PercentBps, p.AmountCents, p.MaxDiscountCents, p.MinFareCents,
+		p.VehicleClasses, p.ZoneIDs, p.FirstRideOnly, p.StartsAt, p.EndsAt, p.MaxRedemptions, p.MaxPerUser,
+		p.CreatedBy,
+	), &out); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicatePromoCode
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresPromoStore) List(ctx context.Context, limit, offset int) ([]PromoCode, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+promoColumns+`
+		FROM promo_codes
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PromoCode, 0)
+	for rows.Next() {
+		var p PromoCode
+		if err := scanPromo(rows, &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresPromoStore) GetByCode(ctx context.Context, code string) (*PromoCode, error) {
+	q := `SELECT ` + promoColumns + ` FROM promo_codes WHERE code = $1 LIMIT 1;`
+	var p PromoCode
+	if err := scanPromo(s.pool.QueryRow(ctx, q, code), &p); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PostgresPromoStore) SetActive(ctx context.Context, id uuid.UUID, active bool) (*PromoCode, error) {
+	q := `UPDATE promo_codes SET active = $2 WHERE id = $1 RETURNING ` + promoColumns
+	var p PromoCode
+	if err := scanPromo(s.pool.QueryRow(ctx, q, id, active), &p); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PostgresPromoStore) UserRedemptions(ctx context.Context, promoID, userID uuid.UUID) (int, error) {
+	var n int
+	err := s.pool.QueryRow(ctx, `
+		SELECT count(*) FROM promo_redemptions
+		WHERE promo_id = $1 AND user_id = $2 AND status = 'applied'
+	`, promoID, userID).Scan(&n)
+	return n, err
+}
+
+func (s *PostgresPromoStore) HasPaidRide(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM payment_intents WHERE user_id = $1 AND amount_captured_cents > 0)
+	`, userID).Scan(&ok)
+	return ok, err
+}
+
+func (s *PostgresPromoStore) Redeem(ctx context.Context, r *PromoRedemption, trip promo.Trip) (*PromoRedemption, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The promo row lock serializes redemptions of one code, so the counts below can't go stale.
+	var p PromoCode
+	if err := scanPromo(tx.QueryRow(ctx, `
+		SELECT `+promoColumns+` FROM promo_codes WHERE id = $1 FOR UPDATE
+	`, r.PromoID), &p); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if !p.Live(time.Now()) {
+		return nil, ErrPromoInactive
+	}
+	if p.MaxRedemptions != nil && p.RedemptionCount >= *p.MaxRedemptions {
+		return nil, ErrPromoExhausted
+	}
+	var used int
+	if err := tx.QueryRow(ctx, `
+		SELECT count(*) FROM promo_redemptions
+		WHERE promo_id = $1 AND user_id = $2 AND status = 'applied'
+	`, r.PromoID, r.UserID).Scan(&used); err != nil {
+		return nil, err
+	}
+	if used >= p.MaxPerUser {
+		return nil, ErrPromoUserLimit
+	}
+
+	trip.FirstRide = false
+	if p.FirstRideOnly {
+		// Lock the user so two first-ride codes can't both be redeemed before either is counted below.
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR NO KEY UPDATE`, r.UserID); err != nil {
+			return nil, err
+		}
+		if err := tx.QueryRow(ctx, `
+			SELECT NOT EXISTS (SELECT 1 FROM payment_intents WHERE user_id = $1 AND amount_captured_cents > 0)
+			   AND NOT EXISTS (
+				SELECT 1 FROM promo_redemptions pr
+				JOIN promo_codes pc ON pc.id = pr.promo_id
+				WHERE pr.user_id = $1 AND pr.status = 'applied' AND pc.first_ride_only
+			)
+		`, r.UserID).Scan(&trip.FirstRide); err != nil {
+			return nil, err
+		}
+	}
+	if err := promo.Check(&p.Rules, trip); err != nil {
+		return nil, err
+	}
+	discount := promo.Discount(&p.Rules, trip.FareCents)
+
+	var out PromoRedemption
+	if err := scanRedemption(tx.QueryRow(ctx, `
+		INSERT INTO promo_redemptions (promo_id, user_id, booking_id, fare_cents, discount_cents)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+redemptionColumns,
+		r.PromoID, r.UserID, r.BookingID, trip.FareCents, discount), &out); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE promo_codes SET redemption_count = redemption_count + 1 WHERE id = $1
+	`, r.PromoID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresPromoStore) Reverse(ctx context.Context, redemptionID uuid.UUID) (*PromoRedemption, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var out PromoRedemption
+	if err := scanRedemption(tx.QueryRow(ctx, `
+		UPDATE promo_redemptions SET status = 'reversed', reversed_at = now()
+		WHERE id = $1 AND status = 'applied'
+		RETURNING `+redemptionColumns,
+		redemptionID), &out); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM promo_redemptions WHERE id = $1)`, redemptionID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrRedemptionReversed
+		}
+		return nil, ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE promo_codes SET redemption_count = redemption_count - 1 WHERE id = $1
+	`, out.PromoID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+var _ PromoStore = (*PostgresPromoStore)(nil)
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/promo"
+	"github.com/google/uuid"
+)
+
+func TestPromoRedeemConcurrentRespectsLimits(t *testing.T) {
+	pool := testPool(t)
+	ps := NewPostgresPromoStore(pool)
+	ctx := context.Background()
+
+	const maxRedemptions, perUser, users, attemptsPerUser = 5, 2, 4, 5
+	limit := maxRedemptions
+	p, err := ps.Create(ctx, &PromoCode{
+		Code:           "TEST" + strings.ToUpper(uuid.NewString()[:8]),
+		Rules:          promo.Rules{Kind: PromoKindFixed, AmountCents: 500},
+		StartsAt:       time.Now().Add(-time.Minute),
+		MaxRedemptions: &limit,
+		MaxPerUser:     perUser,
+	})
+	if err != nil {
+		t.Fatalf("create promo: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM promo_redemptions WHERE promo_id = $1`, p.ID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM promo_codes WHERE id = $1`, p.ID)
+	})
+
+	userIDs := make([]uuid.UUID, users)
+	for i := range userIDs {
+		userIDs[i] = testUser(t, pool)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		ok        int
+		perUserOK = map[uuid.UUID]int{}
+	)
+	for _, uid := range userIDs {
+		for range attemptsPerUser {
+			wg.Add(1)
+			go func(uid uuid.UUID) {
+				defer wg.Done()
+				_, err := ps.Redeem(ctx, &PromoRedemption{PromoID: p.ID, UserID: uid}, promo.Trip{FareCents: 5000})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					ok++
+					perUserOK[uid]++
+				case errors.Is(err, ErrPromoExhausted), errors.Is(err, ErrPromoUserLimit):
+				default:
+					t.Errorf("redeem: %v", err)
+				}
+			}(uid)
+		}
+	}
+	wg.Wait()
+
+	if ok != maxRedemptions {
+		t.Errorf("successful redemptions = %d, want %d", ok, maxRedemptions)
+	}
+	for uid, n := range perUserOK {
+		if n > perUser {
+			t.Errorf("user %s redeemed %d times, limit %d", uid, n, perUser)
+		}
+	}
+	got, err := ps.GetByCode(ctx, p.Code)
+	if err != nil {
+		t.Fatalf("reload promo: %v", err)
+	}
+	if got.RedemptionCount != maxRedemptions {
+		t.Errorf("redemption_count = %d, want %d", got.RedemptionCount, maxRedemptions)
+	}
+	var rows int
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM promo_redemptions WHERE promo_id = $1`, p.ID).Scan(&rows); err != nil {
+		t.Fatalf("count redemptions: %v", err)
+	}
+	if rows != got.RedemptionCount {
+		t.Errorf("redemption rows = %d, redemption_count = %d", rows, got.RedemptionCount)
+	}
+}
+
+func TestPromoReverseFreesARedemption(t *testing.T) {
+	pool := testPool(t)
+	ps := NewPostgresPromoStore(pool)
+	ctx := context.Background()
+
+	limit := 1
+	p, err := ps.Create(ctx, &PromoCode{
+		Code:           "TEST" + strings.ToUpper(uuid.NewString()[:8]),
+		Rules:          promo.Rules{Kind: PromoKindPercentage, PercentBps: 1000},
+		StartsAt:       time.Now().Add(-time.Minute),
+		MaxRedemptions: &limit,
+		MaxPerUser:     1,
+	})
+	if err != nil {
+		t.Fatalf("create promo: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM promo_redemptions WHERE promo_id = $1`, p.ID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM promo_codes WHERE id = $1`, p.ID)
+	})
+	uid := testUser(t, pool)
+
+	red, err := ps.Redeem(ctx, &PromoRedemption{PromoID: p.ID, UserID: uid}, promo.Trip{FareCents: 5000})
+	if err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	if _, err := ps.Redeem(ctx, &PromoRedemption{PromoID: p.ID, UserID: uid}, promo.Trip{FareCents: 5000}); !errors.Is(err, ErrPromoExhausted) {
+		t.Fatalf("second redeem err = %v, want ErrPromoExhausted", err)
+	}
+	if _, err := ps.Reverse(ctx, red.ID); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if _, err := ps.Reverse(ctx, red.ID); !errors.Is(err, ErrRedemptionReversed) {
+		t.Fatalf("second reverse err = %v, want ErrRedemptionReversed", err)
+	}
+	if _, err := ps.Redeem(ctx, &PromoRedemption{PromoID: p.ID, UserID: uid}, promo.Trip{FareCents: 5000}); err != nil {
+		t.Fatalf("redeem after reverse: %v", err)
+	}
+}
+
+func TestPromoRedeemChecksTrip(t *testing.T) {
+	pool := testPool(t)
+	ps := NewPostgresPromoStore(pool)
+	ctx := context.Background()
+
+	maxDiscount := 1500
+	p, err := ps.Create(ctx, &PromoCode{
+		Code: "TEST" + strings.ToUpper(uuid.NewString()[:8]),
+		Rules: promo.Rules{
+			Kind:             PromoKindPercentage,
+			PercentBps:       2000,
+			MaxDiscountCents: &maxDiscount,
+			MinFareCents:     3000,
+			VehicleClasses:   []string{"escalade"},
+		},
+		StartsAt:   time.Now().Add(-time.Minute),
+		MaxPerUser: 5,
+	})
+	if err != nil {
+		t.Fatalf("create promo: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM promo_redemptions WHERE promo_id = $1`, p.ID)
+		_, _ = pool.Exec(context.Background(), `DELETE FROM promo_codes WHERE id = $1`, p.ID)
+	})
+	uid := testUser(t, pool)
+
+	tests := []struct {
+		name         string
+		trip         promo.Trip
+		wantErr      error
+		wantDiscount int64
+	}{
+		{"below minimum", promo.Trip{FareCents: 2999, VehicleClass: "escalade"}, promo.ErrFareTooLow, 0},
+		{"wrong class", promo.Trip{FareCents: 5000, VehicleClass: "sprinter"}, promo.ErrClassNotAllowed, 0},
+		{"eligible", promo.Trip{FareCents: 5000, VehicleClass: "escalade"}, nil, 1000},
+		{"capped", promo.Trip{FareCents: 10000, VehicleClass: "escalade"}, nil, 1500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The caller's amounts must not be trusted.
+			red, err := ps.Redeem(ctx, &PromoRedemption{PromoID: p.ID, UserID: uid, FareCents: 1, DiscountCents: 99999}, tt.trip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("redeem err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if red.FareCents != tt.trip.FareCents || red.DiscountCents != tt.wantDiscount {
+				t.Errorf("redemption fare/discount = %d/%d, want %d/%d", red.FareCents, red.DiscountCents, tt.trip.FareCents, tt.wantDiscount)
+			}
+		})
+	}
+}
+
+func TestPromoRedeemFirstRideOnce(t *testing.T) {
+	pool := testPool(t)
+	ps := NewPostgresPromoStore(pool)
+	ctx := context.Background()
+
+	codes := make([]*PromoCode, 2)
+	for i := range codes {
+		p, err := ps.Create(ctx, &PromoCode{
+			Code:       "TEST" + strings.ToUpper(uuid.NewString()[:8]),
+			Rules:      promo.Rules{Kind: PromoKindFixed, AmountCents: 500, FirstRideOnly: true},
+			StartsAt:   time.Now().Add(-time.Minute),
+			MaxPerUser: 1,
+		})
+		if err != nil {
+			t.Fatalf("create promo: %v", err)
+		}
+		codes[i] = p
+		t.Cleanup(func() {
+			_, _ = pool.Exec(context.Background(), `DELETE FROM promo_redemptions WHERE promo_id = $1`, p.ID)
+			_, _ = pool.Exec(context.Background(), `DELETE FROM promo_codes WHERE id = $1`, p.ID)
+		})
+	}
+	uid := testUser(t, pool)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, p := range codes {
+		wg.Add(1)
+		go func(p *PromoCode) {
+			defer wg.Done()
+			// A caller claiming the trip is a first ride is not believed either; the store decides.
+			_, err := ps.Redeem(ctx, &PromoRedemption{PromoID: p.ID, UserID: uid}, promo.Trip{FareCents: 5000, FirstRide: true})
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, promo.ErrNotFirstRide):
+		default:
+			t.Errorf("redeem: %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("first-ride redemptions = %d, want 1", ok)
+	}
+}
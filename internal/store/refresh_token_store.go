@@ -74,7 +74,8 @@ func ipToNullable(ip net.IP) any {
 	return ip.String()
 }
 
-func toNullString(s string) sql.NullString {
+// ToNullString maps an empty string to NULL.
+func ToNullString(s string) sql.NullString {
 	if strings.TrimSpace(s) == "" {
 		return sql.NullString{}
 	}
@@ -113,7 +114,7 @@ func (s *PostgresRefreshTokenStore) Create(ctx context.Context, userID uuid.UUID
 		Hash:      hashHex(plain),
 		IssuedAt:  now.UTC(),
 		ExpiresAt: now.UTC().Add(ttl),
-		UserAgent: toNullString(ua),
+		UserAgent: ToNullString(ua),
 		IP:        ip,
 	}
 	if err := s.insert(ctx, &rec); err != nil {
@@ -182,7 +183,7 @@ func (s *PostgresRefreshTokenStore) Rotate(ctx context.Context, oldID uuid.UUID,
 		INSERT INTO auth_refresh_tokens (user_id, token_hash, issued_at, expires_at, user_agent, ip)
 		VALUES ($1, $2, $3, $4, $5, $6::inet)
 		RETURNING id, user_id, token_hash, issued_at, expires_at, revoked_at, user_agent, ip
-	`, userID, newHash, nowUTC, newExpires, toNullString(ua), ipToNullable(ip)).
+	`, userID, newHash, nowUTC, newExpires, ToNullString(ua), ipToNullable(ip)).
 		Scan(&rec.ID, &rec.UserID, &rec.Hash, &rec.IssuedAt, &rec.ExpiresAt, &rec.RevokedAt, &rec.UserAgent, &ipStr); err != nil {
 		return "", RefreshToken{}, err
 	}
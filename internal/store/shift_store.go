@@ -0,0 +1,441 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrShiftConflict   = errors.New("shift overlaps an existing shift for this driver or vehicle")
+	ErrTimeOffConflict = errors.New("time off overlaps an existing entry")
+	ErrDriverOnTimeOff = errors.New("driver has time off during this window")
+	ErrTimeOffOnShift  = errors.New("time off overlaps an assigned shift")
+)
+
+// WeeklyAvailability is one recurring slot. Start and End are offsets from local midnight in Timezone;
+// an End at or before Start means the slot runs past midnight.
+type WeeklyAvailability struct {
+	ID        uuid.UUID
+	DriverID  uuid.UUID
+	Weekday   time.Weekday
+	Start     time.Duration
+	End       time.Duration
+	Timezone  string
+	CreatedAt time.Time
+}
+
+type TimeOff struct {
+	ID        uuid.UUID
+	DriverID  uuid.UUID
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Reason    sql.NullString
+	CreatedAt time.Time
+}
+
+type Shift struct {
+	ID        uuid.UUID
+	DriverID  uuid.UUID
+	VehicleID *uuid.UUID
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Notes     sql.NullString
+	CreatedBy *uuid.UUID
+	CreatedAt time.Time
+}
+
+// ShiftCandidate is an approved driver with no time off or shift overlapping the requested window.
+// Weekly availability is returned alongside so callers can check coverage in each slot's own timezone.
+type ShiftCandidate struct {
+	Driver       Driver
+	VehicleID    *uuid.UUID
+	VehicleClass sql.NullString
+	Availability []WeeklyAvailability
+}
+
+type ShiftStore interface {
+	// ReplaceWeeklyAvailability swaps the driver's whole weekly pattern in one transaction.
+	ReplaceWeeklyAvailability(ctx context.Context, driverID uuid.UUID, slots []WeeklyAvailability) ([]WeeklyAvailability, error)
+	ListWeeklyAvailability(ctx context.Context, driverID uuid.UUID) ([]WeeklyAvailability, error)
+	// CreateTimeOff rejects overlaps with the driver's other time off and with shifts already assigned to them.
+	CreateTimeOff(ctx context.Context, t *TimeOff) (*TimeOff, error)
+	ListTimeOff(ctx context.Context, driverID uuid.UUID, from time.Time) ([]TimeOff, error)
+	DeleteTimeOff(ctx context.Context, driverID, id uuid.UUID) error
+	// CreateShift rejects overlaps with the driver's other shifts, the vehicle's other shifts, and time off.
+	CreateShift(ctx context.Context, sh *Shift) (*Shift, error)
+	ListShifts(ctx context.Context, driverID *uuid.UUID, from, to time.Time) ([]Shift, error)
+	DeleteShift(ctx context.Context, id uuid.UUID) error
+	// FindCandidates returns approved drivers free during [start, end); a non-empty class restricts
+	// to drivers currently assigned a serviceable vehicle of that class.
+	FindCandidates(ctx context.Context, start, end time.Time, class string) ([]ShiftCandidate, error)
+}
+
+type PostgresShiftStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresShiftStore(pool *pgxpool.Pool) *PostgresShiftStore {
+	return &PostgresShiftStore{pool: pool}
+}
+
+func isExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01"
+}
+
+func toPgTime(d time.Duration) pgtype.Time {
+	return pgtype.Time{Microseconds: d.Microseconds(), Valid: true}
+}
+
+const availabilityColumns = `id, driver_id, weekday, start_time, end_time, timezone, created_at`
+
+func scanAvailability(row pgx.Row, a *WeeklyAvailability) error {
+	var weekday int16
+	var start, end pgtype.Time
+	if err := row.Scan(&a.ID, &a.DriverID, &weekday, &start, &end, &a.Timezone, &a.CreatedAt); err != nil {
+		return err
+	}
+	a.Weekday = time.Weekday(weekday)
+	a.Start = time.Duration(start.Microseconds) * time.Microsecond
+	a.End = time.Duration(end.Microseconds) * time.Microsecond
+	return nil
+}
+
+const timeOffColumns = `id, driver_id, lower(period), upper(period), reason, created_at`
+
+func scanTimeOff(row pgx.Row, t *TimeOff) error {
+	return row.Scan(&t.ID, &t.DriverID, &t.StartsAt, &t.EndsAt, &t.Reason, &t.CreatedAt)
+}
+
+const shiftColumns = `id, driver_id, vehicle_id, lower(period), upper(period), notes, created_by, created_at`
+
+func scanShift(row pgx.Row, sh *Shift) error {
+	return row.Scan(&sh.ID, &sh.DriverID, &sh.VehicleID, &sh.StartsAt, &sh.EndsAt, &sh.Notes, &sh.CreatedBy, &sh.CreatedAt)
+}
+
+func (s *PostgresShiftStore) ReplaceWeeklyAvailability(ctx context.Context, driverID uuid.UUID, slots []WeeklyAvailability) ([]WeeklyAvailability, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM driver_weekly_availability WHERE driver_id = $1`, driverID); err != nil {
+		return nil, err
+	}
+
+	out := make([]WeeklyAvailability, 0, len(slots))
+	for _, slot := range slots {
+		var a WeeklyAvailability
+		if err := scanAvailability(tx.QueryRow(ctx, `
+			INSERT INTO driver_weekly_availability (driver_id, weekday, start_time, end_time, timezone)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+availabilityColumns,
+			driverID, int16(slot.Weekday), toPgTime(slot.Start), toPgTime(slot.End), slot.Timezone), &a); err != nil {
+			if isForeignKeyViolation(err) {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		out = append(out, a)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *PostgresShiftStore) ListWeeklyAvailability(ctx context.Context, driverID uuid.UUID) ([]WeeklyAvailability, error) {
+	q := `
+		SELECT ` + availabilityColumns + `
+		FROM driver_weekly_availability
+		WHERE driver_id = $1
+		ORDER BY weekday, start_time;
+	`
+	rows, err := s.pool.Query(ctx, q, driverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]WeeklyAvailability, 0)
+	for rows.Next() {
+		var a WeeklyAvailability
+		if err := scanAvailability(rows, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresShiftStore) CreateTimeOff(ctx context.Context, t *TimeOff) (*TimeOff, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Same lock CreateShift takes, so a shift can't be assigned between the check and the insert.
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM drivers WHERE id = $1 FOR UPDATE`, t.DriverID); err != nil {
+		return nil, err
+	}
+
+	var onShift bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM driver_shifts
+			WHERE driver_id = $1 AND period && tstzrange($2, $3, '[)')
+		)
+	`, t.DriverID, t.StartsAt.UTC(), t.EndsAt.UTC()).Scan(&onShift); err != nil {
+		return nil, err
+	}
+	if onShift {
+		return nil, ErrTimeOffOnShift
+	}
+
+	var out TimeOff
+	if err := scanTimeOff(tx.QueryRow(ctx, `
+		INSERT INTO driver_time_off (driver_id, period, reason)
+		VALUES ($1, tstzrange($2, $3, '[)'), $4)
+		RETURNING `+timeOffColumns,
+		t.DriverID, t.StartsAt.UTC(), t.EndsAt.UTC(), t.Reason), &out); err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrTimeOffConflict
+		}
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTimeOff returns entries that have not ended before from, soonest first.
+func (s *PostgresShiftStore) ListTimeOff(ctx context.Context, driverID uuid.UUID, from time.Time) ([]TimeOff, error) {
+	q := `
+		SELECT ` + timeOffColumns + `
+		FROM driver_time_off
+		WHERE driver_id = $1 AND upper(period) > $2
+		ORDER BY lower(period);
+	`
+	rows, err := s.pool.Query(ctx, q, driverID, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TimeOff, 0)
+	for rows.Next() {
+		var t TimeOff
+		if err := scanTimeOff(rows, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresShiftStore) DeleteTimeOff(ctx context.Context, driverID, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM driver_time_off WHERE id = $1 AND driver_id = $2`, id, driverID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresShiftStore) CreateShift(ctx context.Context, sh *Shift) (*Shift, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Lock the driver so a concurrent time-off insert can't slip in between the check and the insert.
+	var driverStatus string
+	if err := tx.QueryRow(ctx, `SELECT status FROM drivers WHERE id = $1 FOR UPDATE`, sh.DriverID).Scan(&driverStatus); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if driverStatus != DriverStatusApproved {
+		return nil, ErrDriverNotApproved
+	}
+
+	if sh.VehicleID != nil {
+		var vehicleStatus string
+		var insured bool
+		if err := tx.QueryRow(ctx, `
+			SELECT status, insurance_expiry >= $2::date
+			FROM vehicles
+			WHERE id = $1
+		`, *sh.VehicleID, sh.EndsAt.UTC()).Scan(&vehicleStatus, &insured); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		if vehicleStatus != VehicleStatusActive {
+			return nil, ErrVehicleUnavailable
+		}
+		if !insured {
+			return nil, ErrInsuranceExpired
+		}
+	}
+
+	var onTimeOff bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM driver_time_off
+			WHERE driver_id = $1 AND period && tstzrange($2, $3, '[)')
+		)
+	`, sh.DriverID, sh.StartsAt.UTC(), sh.EndsAt.UTC()).Scan(&onTimeOff); err != nil {
+		return nil, err
+	}
+	if onTimeOff {
+		return nil, ErrDriverOnTimeOff
+	}
+
+	var out Shift
+	if err := scanShift(tx.QueryRow(ctx, `
+		INSERT INTO driver_shifts (driver_id, vehicle_id, period, notes, created_by)
+		VALUES ($1, $2, tstzrange($3, $4, '[)'), $5, $6)
+		RETURNING `+shiftColumns,
+		sh.DriverID, sh.VehicleID, sh.StartsAt.UTC(), sh.EndsAt.UTC(), sh.Notes, sh.CreatedBy), &out); err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrShiftConflict
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListShifts returns shifts overlapping [from, to); a nil driverID lists the whole fleet.
+func (s *PostgresShiftStore) ListShifts(ctx context.Context, driverID *uuid.UUID, from, to time.Time) ([]Shift, error) {
+	q := `
+		SELECT ` + shiftColumns + `
+		FROM driver_shifts
+		WHERE ($1::uuid IS NULL OR driver_id = $1)
+		  AND period && tstzrange($2, $3, '[)')
+		ORDER BY lower(period);
+	`
+	rows, err := s.pool.Query(ctx, q, driverID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Shift, 0)
+	for rows.Next() {
+		var sh Shift
+		if err := scanShift(rows, &sh); err != nil {
+			return nil, err
+		}
+		out = append(out, sh)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresShiftStore) DeleteShift(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM driver_shifts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresShiftStore) FindCandidates(ctx context.Context, start, end time.Time, class string) ([]ShiftCandidate, error) {
+	q := `
+		SELECT ` + prefixColumns("d", driverColumns) + `, v.id, v.vehicle_class::text
+		FROM drivers d
+		JOIN users u ON u.id = d.user_id
+		LEFT JOIN vehicle_assignments va ON va.driver_id = d.id AND va.unassigned_at IS NULL
+		LEFT JOIN vehicles v ON v.id = va.vehicle_id
+			AND v.status = 'active'
+			AND v.insurance_expiry >= $2::timestamptz::date
+		WHERE d.status = 'approved'
+		  AND u.is_active
+		  AND ($3 = '' OR v.vehicle_class::text = $3)
+		  AND NOT EXISTS (
+			SELECT 1 FROM driver_time_off t
+			WHERE t.driver_id = d.id AND t.period && tstzrange($1, $2, '[)')
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM driver_shifts sh
+			WHERE sh.driver_id = d.id AND sh.period && tstzrange($1, $2, '[)')
+		  )
+		ORDER BY d.rating DESC NULLS LAST, d.submitted_at;
+	`
+	rows, err := s.pool.Query(ctx, q, start.UTC(), end.UTC(), class)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ShiftCandidate, 0)
+	for rows.Next() {
+		var c ShiftCandidate
+		if err := rows.Scan(append(driverDest(&c.Driver), &c.VehicleID, &c.VehicleClass)...); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	ids := make([]uuid.UUID, len(out))
+	index := make(map[uuid.UUID]int, len(out))
+	for i := range out {
+		ids[i] = out[i].Driver.ID
+		index[out[i].Driver.ID] = i
+	}
+	aRows, err := s.pool.Query(ctx, `
+		SELECT `+availabilityColumns+`
+		FROM driver_weekly_availability
+		WHERE driver_id = ANY($1)
+		ORDER BY driver_id, weekday, start_time
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer aRows.Close()
+	for aRows.Next() {
+		var a WeeklyAvailability
+		if err := scanAvailability(aRows, &a); err != nil {
+			return nil, err
+		}
+		i := index[a.DriverID]
+		out[i].Availability = append(out[i].Availability, a)
+	}
+	return out, aRows.Err()
+}
+
+var _ ShiftStore = (*PostgresShiftStore)(nil)
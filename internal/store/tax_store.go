@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	TaxKindPercentage = "percentage"
+	TaxKindFlat       = "flat"
+
+	TaxOnPickup  = "pickup"
+	TaxOnDropoff = "dropoff"
+	TaxOnEither  = "either"
+)
+
+var ErrTaxRuleOverlap = errors.New("tax rule overlaps another version of the same tax in this jurisdiction")
+
+type TaxRule struct {
+	ID          uuid.UUID
+	AreaID      uuid.UUID
+	Code        string
+	Name        string
+	Kind        string
+	RateBps     int
+	AmountCents int
+	AppliesOn   string
+	// EffectiveFrom and EffectiveTo are calendar dates (UTC midnight); EffectiveTo is exclusive.
+	EffectiveFrom time.Time
+	EffectiveTo   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type TaxStore interface {
+	Create(ctx context.Context, tr *TaxRule) (*TaxRule, error)
+	Update(ctx context.Context, tr *TaxRule) (*TaxRule, error)
+	Get(ctx context.Context, id uuid.UUID) (*TaxRule, error)
+	// List returns rules for one jurisdiction, or all rules when areaID is uuid.Nil.
+	List(ctx context.Context, areaID uuid.UUID) ([]TaxRule, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Applicable returns the rules in force on the given date for a trip whose ends fall in the given
+	// jurisdictions.
+	Applicable(ctx context.Context, pickupAreas, dropoffAreas []uuid.UUID, on time.Time) ([]TaxRule, error)
+}
+
+type PostgresTaxStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresTaxStore(pool *pgxpool.Pool) *PostgresTaxStore {
+	return &PostgresTaxStore{pool: pool}
+}
+
+const taxRuleColumns = `
+	id, area_id, code, name, kind, rate_bps, amount_cents, applies_on, effective_from, effective_to,
+	created_at, updated_at`
+
+func scanTaxRule(row pgx.Row, tr *TaxRule) error {
+	return row.Scan(&tr.ID, &tr.AreaID, &tr.Code, &tr.Name, &tr.Kind, &tr.RateBps, &tr.AmountCents, &tr.AppliesOn,
+		&tr.EffectiveFrom, &tr.EffectiveTo, &tr.CreatedAt, &tr.UpdatedAt)
+}
+
+func collectTaxRules(rows pgx.Rows) ([]TaxRule, error) {
+	defer rows.Close()
+	out := make([]TaxRule, 0)
+	for rows.Next() {
+		var tr TaxRule
+		if err := scanTaxRule(rows, &tr); err != nil {
+			return nil, err
+		}
+		out = append(out, tr)
+	}
+	return out, rows.Err()
+}
+
+func taxRuleError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrNotFound
+	case isExclusionViolation(err):
+		return ErrTaxRuleOverlap
+	case isForeignKeyViolation(err):
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *PostgresTaxStore) Create(ctx context.Context, tr *TaxRule) (*TaxRule, error) {
+	q := `
+		INSERT INTO tax_rules (area_id, code, name, kind, rate_bps, amount_cents, applies_on, effective_from, effective_to)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + taxRuleColumns
+	var out TaxRule
+	if err := scanTaxRule(s.pool.QueryRow(ctx, q,
+		tr.AreaID, tr.Code, tr.Name, tr.Kind, tr.RateBps, tr.AmountCents, tr.AppliesOn, tr.EffectiveFrom, tr.EffectiveTo,
+	), &out); err != nil {
+		return nil, taxRuleError(err)
+	}
+	return &out, nil
+}
+
+func (s *PostgresTaxStore) Update(ctx context.Context, tr *TaxRule) (*TaxRule, error) {
+	q := `
+		UPDATE tax_rules SET
+			name = $2, kind = $3, rate_bps = $4, amount_cents = $5, applies_on = $6,
+			effective_from = $7, effective_to = $8
+		WHERE id = $1
+		RETURNING ` + taxRuleColumns
+	var out TaxRule
+	if err := scanTaxRule(s.pool.QueryRow(ctx, q,
+		tr.ID, tr.Name, tr.Kind, tr.RateBps, tr.AmountCents, tr.AppliesOn, tr.EffectiveFrom, tr.EffectiveTo,
+	), &out); err != nil {
+		return nil, taxRuleError(err)
+	}
+	return &out, nil
+}
+
+func (s *PostgresTaxStore) Get(ctx context.Context, id uuid.UUID) (*TaxRule, error) {
+	q := `SELECT ` + taxRuleColumns + ` FROM tax_rules WHERE id = $1 LIMIT 1;`
+	var tr TaxRule
+	if err := scanTaxRule(s.pool.QueryRow(ctx, q, id), &tr); err != nil {
+		return nil, taxRuleError(err)
+	}
+	return &tr, nil
+}
+
+func (s *PostgresTaxStore) List(ctx context.Context, areaID uuid.UUID) ([]TaxRule, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+taxRuleColumns+`
+		FROM tax_rules
+		WHERE ($1 = '00000000-0000-0000-0000-000000000000'::uuid OR area_id = $1)
+		ORDER BY area_id, code, effective_from
+	`, areaID)
+	if err != nil {
+		return nil, err
+	}
+	return collectTaxRules(rows)
+}
+
+func (s *PostgresTaxStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM tax_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresTaxStore) Applicable(ctx context.Context, pickupAreas, dropoffAreas []uuid.UUID, on time.Time) ([]TaxRule, error) {
+	if len(pickupAreas) == 0 && len(dropoffAreas) == 0 {
+		return []TaxRule{}, nil
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+taxRuleColumns+`
+		FROM tax_rules
+		WHERE daterange(effective_from, effective_to) @> $3::date
+		  AND (
+			(applies_on IN ('pickup', 'either') AND area_id = ANY($1::uuid[]))
+			OR (applies_on IN ('dropoff', 'either') AND area_id = ANY($2::uuid[]))
+		  )
+		ORDER BY kind, code
+	`, pickupAreas, dropoffAreas, on)
+	if err != nil {
+		return nil, err
+	}
+	return collectTaxRules(rows)
+}
+
+var _ TaxStore = (*PostgresTaxStore)(nil)
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/diagnosis/luxsuv-api-v2/migrations"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool connects to TEST_DATABASE_URL and applies migrations, skipping the test when it is unset.
+// Point it at a throwaway database: tests create rows and only clean up what they made.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	if err := MigrateFS(dsn, migrations.FS, "."); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	pool, err := OpenPool(dsn)
+	if err != nil {
+		t.Fatalf("open pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// testUser inserts a throwaway rider and removes it, with everything that cascades, when the test ends.
+func testUser(t *testing.T, pool *pgxpool.Pool) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	var id uuid.UUID
+	if err := pool.QueryRow(ctx, `
+		INSERT INTO users (email, password_hash, is_active) VALUES ($1, 'x', true) RETURNING id
+	`, "test-"+uuid.NewString()+"@example.com").Scan(&id); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, id)
+	})
+	return id
+}
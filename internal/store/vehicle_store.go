@@ -0,0 +1,453 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	VehicleClassEscalade = "escalade"
+	VehicleClassSuburban = "suburban"
+	VehicleClassSprinter = "sprinter"
+
+	VehicleStatusActive       = "active"
+	VehicleStatusOutOfService = "out_of_service"
+	VehicleStatusRetired      = "retired"
+)
+
+var (
+	ErrDuplicateVehicle   = errors.New("vin or plate already registered")
+	ErrVehicleUnavailable = errors.New("vehicle is not available for assignment")
+	ErrDriverNotApproved  = errors.New("driver is not approved")
+	ErrNotAssigned        = errors.New("vehicle has no current assignment")
+	ErrInsuranceExpired   = errors.New("vehicle insurance has expired")
+	ErrOdometerRollback   = errors.New("odometer reading is lower than the recorded value")
+	ErrVehicleRetired     = errors.New("vehicle is retired")
+	ErrVehicleInShop      = errors.New("vehicle is out of service for maintenance")
+)
+
+func ValidVehicleClass(c string) bool {
+	switch c {
+	case VehicleClassEscalade, VehicleClassSuburban, VehicleClassSprinter:
+		return true
+	}
+	return false
+}
+
+type Vehicle struct {
+	ID                uuid.UUID
+	VIN               string
+	Plate             string
+	PlateState        string
+	Make              string
+	Model             string
+	Year              int
+	Class             string
+	PassengerCapacity int
+	LuggageCapacity   int
+	Color             string
+	Amenities         []string
+	InsuranceExpiry   time.Time
+	Status            string
+	OdometerMiles     int
+	OdometerUpdatedAt sql.NullTime
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type VehicleAssignment struct {
+	ID           uuid.UUID
+	VehicleID    uuid.UUID
+	DriverID     uuid.UUID
+	AssignedAt   time.Time
+	AssignedBy   *uuid.UUID
+	UnassignedAt sql.NullTime
+	UnassignedBy *uuid.UUID
+	Notes        sql.NullString
+}
+
+type VehicleStore interface {
+	Create(ctx context.Context, v *Vehicle) (*Vehicle, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Vehicle, error)
+	List(ctx context.Context, class, status string, limit, offset int) ([]Vehicle, error)
+	// Update overwrites the mutable fields. Taking a vehicle out of service closes its open assignment;
+	// its status can't be changed while maintenance that took it out of service is in progress.
+	Update(ctx context.Context, v *Vehicle, by uuid.UUID) (*Vehicle, error)
+	// Retire marks the vehicle retired and closes any open assignment.
+	Retire(ctx context.Context, id uuid.UUID, by uuid.UUID) (*Vehicle, error)
+	UpdateOdometer(ctx context.Context, id uuid.UUID, miles int) (*Vehicle, error)
+
+	// Assign closes any open assignment for the vehicle or the driver and opens a new one atomically.
+	// Vehicles that are not active or whose insurance has lapsed are refused.
+	Assign(ctx context.Context, vehicleID, driverID, by uuid.UUID, notes string) (*VehicleAssignment, error)
+	Unassign(ctx context.Context, vehicleID, by uuid.UUID) (*VehicleAssignment, error)
+	ListAssignmentsByVehicle(ctx context.Context, vehicleID uuid.UUID, limit, offset int) ([]VehicleAssignment, error)
+	ListAssignmentsByDriver(ctx context.Context, driverID uuid.UUID, limit, offset int) ([]VehicleAssignment, error)
+	// AssignmentAt returns the assignment that was open for the vehicle at the given instant.
+	AssignmentAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) (*VehicleAssignment, error)
+}
+
+type PostgresVehicleStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresVehicleStore(pool *pgxpool.Pool) *PostgresVehicleStore {
+	return &PostgresVehicleStore{pool: pool}
+}
+
+const vehicleColumns = `
+	id, vin, plate, plate_state, make, model, year, vehicle_class, passenger_capacity, luggage_capacity,
+	color, amenities, insurance_expiry, status, odometer_miles, odometer_updated_at, created_at, updated_at`
+
+func scanVehicle(row pgx.Row, v *Vehicle) error {
+	var year, pax, luggage int16
+	if err := row.Scan(
+		&v.ID, &v.VIN, &v.Plate, &v.PlateState, &v.Make, &v.Model, &year, &v.Class, &pax, &luggage,
+		&v.Color, &v.Amenities, &v.InsuranceExpiry, &v.Status, &v.OdometerMiles, &v.OdometerUpdatedAt, &v.CreatedAt, &v.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	v.Year, v.PassengerCapacity, v.LuggageCapacity = int(year), int(pax), int(luggage)
+	return nil
+}
+
+const assignmentColumns = `id, vehicle_id, driver_id, assigned_at, assigned_by, unassigned_at, unassigned_by, notes`
+
+func scanAssignment(row pgx.Row, a *VehicleAssignment) error {
+	return row.Scan(&a.ID, &a.VehicleID, &a.DriverID, &a.AssignedAt, &a.AssignedBy, &a.UnassignedAt, &a.UnassignedBy, &a.Notes)
+}
+
+func collectAssignments(rows pgx.Rows) ([]VehicleAssignment, error) {
+	defer rows.Close()
+	out := make([]VehicleAssignment, 0)
+	for rows.Next() {
+		var a VehicleAssignment
+		if err := scanAssignment(rows, &a); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func normalizeAmenities(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, a := range in {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "" || seen[a] {
+			continue
+		}
+		seen[a] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+func (s *PostgresVehicleStore) Create(ctx context.Context, v *Vehicle) (*Vehicle, error) {
+	q := `
+		INSERT INTO vehicles
+			(vin, plate, plate_state, make, model, year, vehicle_class, passenger_capacity, luggage_capacity,
+			 color, amenities, insurance_expiry, status)
+		VALUES ($1, upper(btrim($2)), upper(btrim($3)), btrim($4), btrim($5), $6, $7, $8, $9, btrim($10), $11, $12,
+			COALESCE(NULLIF($13, ''), 'active')::vehicle_status)
+		RETURNING ` + vehicleColumns
+	var out Vehicle
+	if err := scanVehicle(s.pool.QueryRow(ctx, q,
+		strings.ToUpper(strings.TrimSpace(v.VIN)), v.Plate, v.PlateState, v.Make, v.Model, v.Year, v.Class,
+		v.PassengerCapacity, v.LuggageCapacity, v.Color, normalizeAmenities(v.Amenities), v.InsuranceExpiry, v.Status,
+	), &out); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateVehicle
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresVehicleStore) GetByID(ctx context.Context, id uuid.UUID) (*Vehicle, error) {
+	q := `SELECT ` + vehicleColumns + ` FROM vehicles WHERE id = $1 LIMIT 1;`
+	var v Vehicle
+	if err := scanVehicle(s.pool.QueryRow(ctx, q, id), &v); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (s *PostgresVehicleStore) List(ctx context.Context, class, status string, limit, offset int) ([]Vehicle, error) {
+	q := `
+		SELECT ` + vehicleColumns + `
+		FROM vehicles
+		WHERE ($1 = '' OR vehicle_class::text = $1)
+		  AND ($2 = '' OR status::text = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4;
+	`
+	rows, err := s.pool.Query(ctx, q, class, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Vehicle, 0)
+	for rows.Next() {
+		var v Vehicle
+		if err := scanVehicle(rows, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Update overwrites the mutable fields; the VIN is fixed once registered. Retired vehicles are refused
+// with ErrVehicleRetired, including ones retired since the caller read them.
+func (s *PostgresVehicleStore) Update(ctx context.Context, v *Vehicle, by uuid.UUID) (*Vehicle, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Locking the vehicle serializes this with Retire and with maintenance starting or finishing.
+	var current string
+	if err := tx.QueryRow(ctx, `SELECT status FROM vehicles WHERE id = $1 FOR UPDATE`, v.ID).Scan(&current); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if current == VehicleStatusRetired {
+		return nil, ErrVehicleRetired
+	}
+	if v.Status != current {
+		var inShop bool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM maintenance_records
+				WHERE vehicle_id = $1 AND status = 'in_progress' AND took_out_of_service
+			)
+		`, v.ID).Scan(&inShop); err != nil {
+			return nil, err
+		}
+		if inShop {
+			return nil, ErrVehicleInShop
+		}
+	}
+
+	q := `
+		UPDATE vehicles SET
+			plate = upper(btrim($2)), plate_state = upper(btrim($3)), make = btrim($4), model = btrim($5),
+			year = $6, vehicle_class = $7, passenger_capacity = $8, luggage_capacity = $9,
+			color = btrim($10), amenities = $11, insurance_expiry = $12, status = $13
+		WHERE id = $1
+		RETURNING ` + vehicleColumns
+	var out Vehicle
+	if err := scanVehicle(tx.QueryRow(ctx, q,
+		v.ID, v.Plate, v.PlateState, v.Make, v.Model, v.Year, v.Class, v.PassengerCapacity, v.LuggageCapacity,
+		v.Color, normalizeAmenities(v.Amenities), v.InsuranceExpiry, v.Status,
+	), &out); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateVehicle
+		}
+		return nil, err
+	}
+	if out.Status == VehicleStatusOutOfService && current != VehicleStatusOutOfService {
+		if _, err := tx.Exec(ctx, `
+			UPDATE vehicle_assignments SET unassigned_at = now(), unassigned_by = $2
+			WHERE vehicle_id = $1 AND unassigned_at IS NULL
+		`, v.ID, by); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *PostgresVehicleStore) Retire(ctx context.Context, id uuid.UUID, by uuid.UUID) (*Vehicle, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var v Vehicle
+	if err := scanVehicle(tx.QueryRow(ctx, `
+		UPDATE vehicles SET status = 'retired'
+		WHERE id = $1
+		RETURNING `+vehicleColumns, id), &v); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE vehicle_assignments SET unassigned_at = now(), unassigned_by = $2
+		WHERE vehicle_id = $1 AND unassigned_at IS NULL
+	`, id, by); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (s *PostgresVehicleStore) UpdateOdometer(ctx context.Context, id uuid.UUID, miles int) (*Vehicle, error) {
+	q := `
+		UPDATE vehicles SET odometer_miles = $2, odometer_updated_at = now()
+		WHERE id = $1 AND odometer_miles <= $2
+		RETURNING ` + vehicleColumns
+	var v Vehicle
+	if err := scanVehicle(s.pool.QueryRow(ctx, q, id, miles), &v); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, getErr := s.GetByID(ctx, id); getErr != nil {
+				return nil, getErr
+			}
+			return nil, ErrOdometerRollback
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (s *PostgresVehicleStore) Assign(ctx context.Context, vehicleID, driverID, by uuid.UUID, notes string) (*VehicleAssignment, error) {
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Lock both sides so concurrent assignments serialize on the same rows.
+	var vehicleStatus string
+	var insured bool
+	if err := tx.QueryRow(ctx, `
+		SELECT status, insurance_expiry >= current_date
+		FROM vehicles
+		WHERE id = $1
+		FOR UPDATE
+	`, vehicleID).Scan(&vehicleStatus, &insured); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if vehicleStatus != VehicleStatusActive {
+		return nil, ErrVehicleUnavailable
+	}
+	if !insured {
+		return nil, ErrInsuranceExpired
+	}
+
+	var driverStatus string
+	if err := tx.QueryRow(ctx, `SELECT status FROM drivers WHERE id = $1 FOR UPDATE`, driverID).Scan(&driverStatus); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if driverStatus != DriverStatusApproved {
+		return nil, ErrDriverNotApproved
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE vehicle_assignments SET unassigned_at = now(), unassigned_by = $3
+		WHERE (vehicle_id = $1 OR driver_id = $2) AND unassigned_at IS NULL
+	`, vehicleID, driverID, by); err != nil {
+		return nil, err
+	}
+
+	var a VehicleAssignment
+	if err := scanAssignment(tx.QueryRow(ctx, `
+		INSERT INTO vehicle_assignments (vehicle_id, driver_id, assigned_by, notes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+assignmentColumns, vehicleID, driverID, by, ToNullString(notes)), &a); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *PostgresVehicleStore) Unassign(ctx context.Context, vehicleID, by uuid.UUID) (*VehicleAssignment, error) {
+	q := `
+		UPDATE vehicle_assignments SET unassigned_at = now(), unassigned_by = $2
+		WHERE vehicle_id = $1 AND unassigned_at IS NULL
+		RETURNING ` + assignmentColumns
+	var a VehicleAssignment
+	if err := scanAssignment(s.pool.QueryRow(ctx, q, vehicleID, by), &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotAssigned
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *PostgresVehicleStore) ListAssignmentsByVehicle(ctx context.Context, vehicleID uuid.UUID, limit, offset int) ([]VehicleAssignment, error) {
+	q := `
+		SELECT ` + assignmentColumns + `
+		FROM vehicle_assignments
+		WHERE vehicle_id = $1
+		ORDER BY assigned_at DESC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := s.pool.Query(ctx, q, vehicleID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectAssignments(rows)
+}
+
+func (s *PostgresVehicleStore) ListAssignmentsByDriver(ctx context.Context, driverID uuid.UUID, limit, offset int) ([]VehicleAssignment, error) {
+	q := `
+		SELECT ` + assignmentColumns + `
+		FROM vehicle_assignments
+		WHERE driver_id = $1
+		ORDER BY assigned_at DESC
+		LIMIT $2 OFFSET $3;
+	`
+	rows, err := s.pool.Query(ctx, q, driverID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectAssignments(rows)
+}
+
+func (s *PostgresVehicleStore) AssignmentAt(ctx context.Context, vehicleID uuid.UUID, at time.Time) (*VehicleAssignment, error) {
+	q := `
+		SELECT ` + assignmentColumns + `
+		FROM vehicle_assignments
+		WHERE vehicle_id = $1
+		  AND assigned_at <= $2
+		  AND (unassigned_at IS NULL OR unassigned_at > $2)
+		ORDER BY assigned_at DESC
+		LIMIT 1;
+	`
+	var a VehicleAssignment
+	if err := scanAssignment(s.pool.QueryRow(ctx, q, vehicleID, at.UTC()), &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+var _ VehicleStore = (*PostgresVehicleStore)(nil)
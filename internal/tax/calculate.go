@@ -0,0 +1,38 @@
+package tax
+
+import (
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+// Line is one itemized tax or fee on a fare.
+type Line struct {
+	RuleID      uuid.UUID
+	Code        string
+	Name        string
+	Kind        string
+	RateBps     int
+	AmountCents int64
+}
+
+// Calculate applies rules to a pre-tax fare. Percentage taxes are charged on the fare only, never on
+// other taxes, and rounded half-up to the cent. It returns the lines in rule order and their total.
+func Calculate(fareCents int64, rules []store.TaxRule) ([]Line, int64) {
+	lines := make([]Line, 0, len(rules))
+	var total int64
+	for _, r := range rules {
+		l := Line{RuleID: r.ID, Code: r.Code, Name: r.Name, Kind: r.Kind}
+		switch r.Kind {
+		case store.TaxKindPercentage:
+			l.RateBps = r.RateBps
+			l.AmountCents = (fareCents*int64(r.RateBps) + 5000) / 10000
+		case store.TaxKindFlat:
+			l.AmountCents = int64(r.AmountCents)
+		default:
+			continue
+		}
+		total += l.AmountCents
+		lines = append(lines, l)
+	}
+	return lines, total
+}
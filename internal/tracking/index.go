@@ -0,0 +1,59 @@
+package tracking
+
+import (
+	"sync"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+	"github.com/google/uuid"
+)
+
+// Index keeps the most recent position per driver in memory so dispatch and live tracking
+// don't need a database round trip for every lookup.
+type Index struct {
+	mu     sync.RWMutex
+	latest map[uuid.UUID]store.LocationPing
+	maxAge time.Duration
+}
+
+// NewIndex creates an index that treats positions older than maxAge as stale.
+func NewIndex(maxAge time.Duration) *Index {
+	return &Index{latest: make(map[uuid.UUID]store.LocationPing), maxAge: maxAge}
+}
+
+// Update records p unless a newer fix is already held for the driver, so late-arriving pings
+// never move a driver backwards. It reports whether the stored position changed.
+func (i *Index) Update(p store.LocationPing) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if cur, ok := i.latest[p.DriverID]; ok && !p.RecordedAt.After(cur.RecordedAt) {
+		return false
+	}
+	i.latest[p.DriverID] = p
+	return true
+}
+
+// Get returns the driver's latest position if it is fresher than the index's max age.
+func (i *Index) Get(driverID uuid.UUID, now time.Time) (store.LocationPing, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	p, ok := i.latest[driverID]
+	if !ok || now.Sub(p.RecordedAt) > i.maxAge {
+		return store.LocationPing{}, false
+	}
+	return p, true
+}
+
+// Prune drops stale positions so drivers who went offline don't accumulate forever.
+func (i *Index) Prune(now time.Time) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	n := 0
+	for id, p := range i.latest {
+		if now.Sub(p.RecordedAt) > i.maxAge {
+			delete(i.latest, id)
+			n++
+		}
+	}
+	return n
+}
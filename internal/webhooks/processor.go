@@ -0,0 +1,135 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diagnosis/luxsuv-api-v2/internal/logger"
+	"github.com/diagnosis/luxsuv-api-v2/internal/payments"
+	"github.com/diagnosis/luxsuv-api-v2/internal/store"
+)
+
+// ErrUnknownIntent means the event refers to a payment intent we have no record of yet. It is kept as
+// a processing error so the event can be replayed once the intent exists.
+var ErrUnknownIntent = errors.New("payment intent not found for event")
+
+type handlerFunc func(ctx context.Context, provider string, ev *payments.Event) error
+
+// Processor applies stored provider events to payment intents. It is shared by the webhook endpoint
+// and the replay command, so every handler must be safe to run more than once for the same event.
+//
+// Bookings are not modelled in this schema yet; once they are, the handlers are the place to move a
+// booking along with its payment.
+type Processor struct {
+	Payments store.PaymentStore
+	Events   store.PaymentEventStore
+	handlers map[string]handlerFunc
+}
+
+func NewProcessor(ps store.PaymentStore, es store.PaymentEventStore) *Processor {
+	p := &Processor{Payments: ps, Events: es}
+	p.handlers = map[string]handlerFunc{
+		payments.EventPaymentSucceeded: p.onSucceeded,
+		payments.EventPaymentFailed:    p.onFailed,
+		payments.EventPaymentCanceled:  p.onCanceled,
+		payments.EventChargeRefunded:   p.onRefunded,
+		payments.EventDisputeCreated:   p.onDisputed,
+	}
+	return p
+}
+
+// Process runs the handler for a stored event and records the outcome on it. Event types without a
+// handler are marked processed so they don't show up as pending.
+func (p *Processor) Process(ctx context.Context, e *store.PaymentEvent) error {
+	ev, err := payments.ParseEvent(e.Payload)
+	if err == nil {
+		if h, ok := p.handlers[ev.Type]; ok {
+			err = h(ctx, e.Provider, ev)
+		} else {
+			logger.Debug(ctx, "ignoring payment event", "event_id", e.EventID, "type", e.EventType)
+		}
+	}
+	if markErr := p.Events.MarkResult(ctx, e.ID, err); markErr != nil {
+		return errors.Join(err, markErr)
+	}
+	return err
+}
+
+func (p *Processor) intent(ctx context.Context, provider string, ev *payments.Event) (*store.PaymentIntent, error) {
+	if ev.IntentID == "" {
+		return nil, fmt.Errorf("%w: event carries no payment intent", payments.ErrMalformedEvent)
+	}
+	pi, err := p.Payments.GetIntentByProviderID(ctx, provider, ev.IntentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIntent, ev.IntentID)
+	}
+	return pi, err
+}
+
+// update applies fn to the event's intent under a row lock, so each handler sees the amounts as they
+// are now rather than as they were when the event arrived.
+func (p *Processor) update(ctx context.Context, provider string, ev *payments.Event, fn func(pi *store.PaymentIntent)) error {
+	pi, err := p.intent(ctx, provider, ev)
+	if err != nil {
+		return err
+	}
+	_, err = p.Payments.UpdateIntent(ctx, pi.ID, func(pi *store.PaymentIntent) error {
+		fn(pi)
+		return nil
+	})
+	return err
+}
+
+func (p *Processor) onSucceeded(ctx context.Context, provider string, ev *payments.Event) error {
+	return p.update(ctx, provider, ev, func(pi *store.PaymentIntent) {
+		pi.Status = store.PaymentIntentSucceeded
+		pi.AmountCapturedCents = min(max(pi.AmountCapturedCents, ev.AmountCapturedCents), pi.AmountAuthorizedCents)
+		pi.FailureReason = sql.NullString{}
+	})
+}
+
+func (p *Processor) onFailed(ctx context.Context, provider string, ev *payments.Event) error {
+	return p.update(ctx, provider, ev, func(pi *store.PaymentIntent) {
+		if pi.Status == store.PaymentIntentSucceeded {
+			// A late failure for an earlier attempt must not undo a capture we already know about.
+			return
+		}
+		pi.Status = store.PaymentIntentFailed
+		pi.FailureReason = sql.NullString{String: ev.FailureReason, Valid: ev.FailureReason != ""}
+	})
+}
+
+func (p *Processor) onCanceled(ctx context.Context, provider string, ev *payments.Event) error {
+	return p.update(ctx, provider, ev, func(pi *store.PaymentIntent) {
+		if pi.Status == store.PaymentIntentRequiresCapture {
+			pi.Status = store.PaymentIntentCanceled
+		}
+	})
+}
+
+func (p *Processor) onRefunded(ctx context.Context, provider string, ev *payments.Event) error {
+	return p.update(ctx, provider, ev, func(pi *store.PaymentIntent) {
+		// Events can arrive out of order; amounts only ever grow.
+		pi.AmountCapturedCents = min(max(pi.AmountCapturedCents, ev.AmountCapturedCents), pi.AmountAuthorizedCents)
+		pi.AmountRefundedCents = min(max(pi.AmountRefundedCents, ev.AmountRefundedCents), pi.AmountCapturedCents)
+	})
+}
+
+func (p *Processor) onDisputed(ctx context.Context, provider string, ev *payments.Event) error {
+	pi, err := p.intent(ctx, provider, ev)
+	if err != nil {
+		return err
+	}
+	at := ev.Created
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if _, err := p.Payments.MarkIntentDisputed(ctx, pi.ID, at); err != nil {
+		return err
+	}
+	logger.Warn(ctx, "payment disputed", "payment_intent_id", pi.ID, "user_id", pi.UserID, "reason", ev.FailureReason)
+	return nil
+}
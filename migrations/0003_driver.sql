@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'driver_status') THEN
+CREATE TYPE driver_status AS ENUM ('pending','approved','rejected');
+END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'background_check_status') THEN
+CREATE TYPE background_check_status AS ENUM ('not_started','pending','clear','failed');
+END IF;
+END$$;
+
+CREATE TABLE drivers (
+    id                      UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id                 UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    first_name              VARCHAR(100) NOT NULL,
+    last_name               VARCHAR(100) NOT NULL,
+    phone                   VARCHAR(32)  NOT NULL,
+    license_number          VARCHAR(64)  NOT NULL,
+    license_state           VARCHAR(8)   NOT NULL,
+    license_expiry          DATE         NOT NULL,
+    home_base               VARCHAR(255) NOT NULL,
+    background_check_status background_check_status NOT NULL DEFAULT 'not_started',
+    rating                  NUMERIC(3,2) CHECK (rating IS NULL OR (rating >= 0 AND rating <= 5)),
+    status                  driver_status NOT NULL DEFAULT 'pending',
+    rejection_reason        TEXT,
+    reviewed_by             UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at             TIMESTAMPTZ,
+    submitted_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One license number per issuing state
+CREATE UNIQUE INDEX IF NOT EXISTS drivers_license_unique
+    ON drivers (license_state, upper(btrim(license_number)));
+
+CREATE INDEX IF NOT EXISTS idx_drivers_status ON drivers(status);
+
+CREATE TRIGGER trg_drivers_updated_at
+    BEFORE UPDATE ON drivers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS trg_drivers_updated_at ON drivers;
+DROP TABLE IF EXISTS drivers;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'background_check_status') THEN
+DROP TYPE background_check_status;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'driver_status') THEN
+DROP TYPE driver_status;
+END IF;
+END$$;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'driver_document_type') THEN
+CREATE TYPE driver_document_type AS ENUM ('license','insurance','chauffeur_permit');
+END IF;
+END$$;
+
+CREATE TABLE driver_documents (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    driver_id     UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    doc_type      driver_document_type NOT NULL,
+    storage_key   TEXT NOT NULL UNIQUE,
+    file_name     VARCHAR(255) NOT NULL,
+    content_type  VARCHAR(100) NOT NULL,
+    size_bytes    BIGINT NOT NULL CHECK (size_bytes > 0),
+    sha256        CHAR(64) NOT NULL,
+    expires_on    DATE,
+    uploaded_by   UUID REFERENCES users(id) ON DELETE SET NULL,
+    uploaded_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_documents_driver   ON driver_documents(driver_id, doc_type, uploaded_at DESC);
+CREATE INDEX IF NOT EXISTS idx_driver_documents_expires  ON driver_documents(expires_on) WHERE expires_on IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS driver_documents;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'driver_document_type') THEN
+DROP TYPE driver_document_type;
+END IF;
+END$$;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'vehicle_class') THEN
+CREATE TYPE vehicle_class AS ENUM ('escalade','suburban','sprinter');
+END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'vehicle_status') THEN
+CREATE TYPE vehicle_status AS ENUM ('active','out_of_service','retired');
+END IF;
+END$$;
+
+CREATE TABLE vehicles (
+    id                 UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    vin                CHAR(17)     NOT NULL UNIQUE,
+    plate              VARCHAR(16)  NOT NULL,
+    plate_state        VARCHAR(8)   NOT NULL,
+    make               VARCHAR(64)  NOT NULL,
+    model              VARCHAR(64)  NOT NULL,
+    year               SMALLINT     NOT NULL CHECK (year BETWEEN 1980 AND 2100),
+    vehicle_class      vehicle_class NOT NULL,
+    passenger_capacity SMALLINT     NOT NULL CHECK (passenger_capacity > 0),
+    luggage_capacity   SMALLINT     NOT NULL DEFAULT 0 CHECK (luggage_capacity >= 0),
+    color              VARCHAR(32)  NOT NULL,
+    amenities          TEXT[]       NOT NULL DEFAULT '{}',
+    insurance_expiry   DATE         NOT NULL,
+    status             vehicle_status NOT NULL DEFAULT 'active',
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS vehicles_plate_unique ON vehicles (plate_state, upper(plate));
+CREATE INDEX IF NOT EXISTS idx_vehicles_class_status ON vehicles(vehicle_class, status);
+
+CREATE TRIGGER trg_vehicles_updated_at
+    BEFORE UPDATE ON vehicles
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Assignment history: an open row (unassigned_at IS NULL) is the current pairing.
+CREATE TABLE vehicle_assignments (
+    id             UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    vehicle_id     UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+    driver_id      UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    assigned_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    assigned_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    unassigned_at  TIMESTAMPTZ,
+    unassigned_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+    notes          TEXT,
+    CHECK (unassigned_at IS NULL OR unassigned_at >= assigned_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS vehicle_assignments_open_vehicle
+    ON vehicle_assignments(vehicle_id) WHERE unassigned_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS vehicle_assignments_open_driver
+    ON vehicle_assignments(driver_id) WHERE unassigned_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_vehicle_assignments_vehicle ON vehicle_assignments(vehicle_id, assigned_at DESC);
+CREATE INDEX IF NOT EXISTS idx_vehicle_assignments_driver  ON vehicle_assignments(driver_id, assigned_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS vehicle_assignments;
+DROP TRIGGER IF EXISTS trg_vehicles_updated_at ON vehicles;
+DROP TABLE IF EXISTS vehicles;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'vehicle_status') THEN
+DROP TYPE vehicle_status;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'vehicle_class') THEN
+DROP TYPE vehicle_class;
+END IF;
+END$$;
+-- +goose StatementEnd
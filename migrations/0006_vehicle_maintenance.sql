@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'maintenance_due_state') THEN
+CREATE TYPE maintenance_due_state AS ENUM ('ok','due','overdue');
+END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'maintenance_status') THEN
+CREATE TYPE maintenance_status AS ENUM ('scheduled','in_progress','completed','cancelled');
+END IF;
+END$$;
+
+ALTER TABLE vehicles
+    ADD COLUMN odometer_miles      INTEGER NOT NULL DEFAULT 0 CHECK (odometer_miles >= 0),
+    ADD COLUMN odometer_updated_at TIMESTAMPTZ;
+
+-- Recurring service intervals; whichever of date or mileage comes first makes the schedule due.
+CREATE TABLE maintenance_schedules (
+    id                   UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    vehicle_id           UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+    kind                 VARCHAR(64) NOT NULL,
+    interval_days        INTEGER CHECK (interval_days IS NULL OR interval_days > 0),
+    interval_miles       INTEGER CHECK (interval_miles IS NULL OR interval_miles > 0),
+    last_performed_on    DATE NOT NULL,
+    last_performed_miles INTEGER NOT NULL DEFAULT 0 CHECK (last_performed_miles >= 0),
+    next_due_on          DATE GENERATED ALWAYS AS (last_performed_on + interval_days) STORED,
+    next_due_miles       INTEGER GENERATED ALWAYS AS (last_performed_miles + interval_miles) STORED,
+    due_state            maintenance_due_state NOT NULL DEFAULT 'ok',
+    checked_at           TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (interval_days IS NOT NULL OR interval_miles IS NOT NULL),
+    UNIQUE (vehicle_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_schedules_due ON maintenance_schedules(due_state) WHERE due_state <> 'ok';
+
+CREATE TRIGGER trg_maintenance_schedules_updated_at
+    BEFORE UPDATE ON maintenance_schedules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE maintenance_records (
+    id                  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    vehicle_id          UUID NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+    schedule_id         UUID REFERENCES maintenance_schedules(id) ON DELETE SET NULL,
+    kind                VARCHAR(64) NOT NULL,
+    description         TEXT,
+    vendor              VARCHAR(255),
+    status              maintenance_status NOT NULL DEFAULT 'scheduled',
+    scheduled_for       DATE NOT NULL,
+    started_at          TIMESTAMPTZ,
+    completed_at        TIMESTAMPTZ,
+    odometer_miles      INTEGER CHECK (odometer_miles IS NULL OR odometer_miles >= 0),
+    cost_cents          BIGINT CHECK (cost_cents IS NULL OR cost_cents >= 0),
+    notes               TEXT,
+    -- set when starting this record moved the vehicle out of service, so completion only restores what it changed
+    took_out_of_service BOOLEAN NOT NULL DEFAULT false,
+    created_by          UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_records_vehicle ON maintenance_records(vehicle_id, scheduled_for DESC);
+CREATE INDEX IF NOT EXISTS idx_maintenance_records_open    ON maintenance_records(vehicle_id) WHERE status = 'in_progress';
+
+CREATE TRIGGER trg_maintenance_records_updated_at
+    BEFORE UPDATE ON maintenance_records
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS trg_maintenance_records_updated_at ON maintenance_records;
+DROP TABLE IF EXISTS maintenance_records;
+DROP TRIGGER IF EXISTS trg_maintenance_schedules_updated_at ON maintenance_schedules;
+DROP TABLE IF EXISTS maintenance_schedules;
+
+ALTER TABLE vehicles
+    DROP COLUMN IF EXISTS odometer_updated_at,
+    DROP COLUMN IF EXISTS odometer_miles;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'maintenance_status') THEN
+DROP TYPE maintenance_status;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'maintenance_due_state') THEN
+DROP TYPE maintenance_due_state;
+END IF;
+END$$;
+-- +goose StatementEnd
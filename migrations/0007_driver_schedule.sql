@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+-- btree_gist lets exclusion constraints combine driver_id equality with range overlap
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE drivers
+    ADD COLUMN on_duty         BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN duty_changed_at TIMESTAMPTZ;
+
+-- Recurring weekly availability; end_time <= start_time means the window runs past midnight.
+CREATE TABLE driver_weekly_availability (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    driver_id   UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    weekday     SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time  TIME NOT NULL,
+    end_time    TIME NOT NULL,
+    timezone    VARCHAR(64) NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_weekly_availability_driver ON driver_weekly_availability(driver_id);
+
+CREATE TABLE driver_time_off (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    driver_id   UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    period      TSTZRANGE NOT NULL CHECK (NOT isempty(period)),
+    reason      TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    EXCLUDE USING gist (driver_id WITH =, period WITH &&)
+);
+
+-- Concrete working shifts; neither a driver nor a vehicle can be in two overlapping shifts.
+CREATE TABLE driver_shifts (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    driver_id   UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    vehicle_id  UUID REFERENCES vehicles(id) ON DELETE SET NULL,
+    period      TSTZRANGE NOT NULL CHECK (NOT isempty(period)),
+    notes       TEXT,
+    created_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT driver_shifts_no_driver_overlap EXCLUDE USING gist (driver_id WITH =, period WITH &&),
+    CONSTRAINT driver_shifts_no_vehicle_overlap EXCLUDE USING gist (vehicle_id WITH =, period WITH &&) WHERE (vehicle_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_shifts_period ON driver_shifts USING gist (period);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS driver_shifts;
+DROP TABLE IF EXISTS driver_time_off;
+DROP TABLE IF EXISTS driver_weekly_availability;
+
+ALTER TABLE drivers
+    DROP COLUMN IF EXISTS duty_changed_at,
+    DROP COLUMN IF EXISTS on_duty;
+-- +goose StatementEnd
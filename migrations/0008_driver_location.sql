@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GPS breadcrumbs, partitioned by month so old data can be detached or dropped cheaply.
+-- The primary key must include the partition key; it also deduplicates resent pings.
+CREATE TABLE driver_locations (
+    driver_id    UUID NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    recorded_at  TIMESTAMPTZ NOT NULL,
+    lat          DOUBLE PRECISION NOT NULL CHECK (lat BETWEEN -90 AND 90),
+    lng          DOUBLE PRECISION NOT NULL CHECK (lng BETWEEN -180 AND 180),
+    heading      REAL CHECK (heading >= 0 AND heading < 360),
+    speed_mps    REAL CHECK (speed_mps >= 0),
+    accuracy_m   REAL CHECK (accuracy_m >= 0),
+    received_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (driver_id, recorded_at)
+) PARTITION BY RANGE (recorded_at);
+
+-- Catches rows outside any monthly partition so inserts never fail; the partition job keeps it small.
+CREATE TABLE driver_locations_default PARTITION OF driver_locations DEFAULT;
+
+-- Creates the monthly partition containing the given instant if it does not exist yet.
+CREATE OR REPLACE FUNCTION ensure_driver_locations_partition(at TIMESTAMPTZ)
+RETURNS void AS $$
+DECLARE
+    month_start DATE := date_trunc('month', at AT TIME ZONE 'UTC')::date;
+    part_name   TEXT := 'driver_locations_' || to_char(month_start, 'YYYYMM');
+BEGIN
+    IF to_regclass(part_name) IS NULL THEN
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF driver_locations FOR VALUES FROM (%L) TO (%L)',
+            part_name,
+            (month_start::timestamp AT TIME ZONE 'UTC'),
+            ((month_start + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC')
+        );
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT ensure_driver_locations_partition(now());
+SELECT ensure_driver_locations_partition(now() + INTERVAL '1 month');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS driver_locations;
+DROP FUNCTION IF EXISTS ensure_driver_locations_partition(TIMESTAMPTZ);
+-- +goose StatementEnd
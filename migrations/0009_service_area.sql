@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'area_kind') THEN
+        CREATE TYPE area_kind AS ENUM ('service_area', 'zone');
+    END IF;
+END$$;
+
+-- service_area polygons bound where we operate; zone polygons (airports, downtown) drive flat rates.
+-- Geometry is a GeoJSON MultiPolygon; the bbox columns prefilter point lookups.
+CREATE TABLE service_areas (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name        VARCHAR(100) NOT NULL UNIQUE,
+    kind        area_kind NOT NULL,
+    geometry    JSONB NOT NULL,
+    min_lat     DOUBLE PRECISION NOT NULL,
+    min_lng     DOUBLE PRECISION NOT NULL,
+    max_lat     DOUBLE PRECISION NOT NULL,
+    max_lng     DOUBLE PRECISION NOT NULL,
+    active      BOOLEAN NOT NULL DEFAULT true,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_areas_bbox ON service_areas(kind, min_lat, max_lat, min_lng, max_lng) WHERE active;
+
+CREATE TRIGGER trg_service_areas_updated_at
+    BEFORE UPDATE ON service_areas
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Flat rate between two zones for a vehicle class; direction matters (airport -> downtown may differ).
+CREATE TABLE zone_rates (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_zone_id    UUID NOT NULL REFERENCES service_areas(id) ON DELETE CASCADE,
+    to_zone_id      UUID NOT NULL REFERENCES service_areas(id) ON DELETE CASCADE,
+    vehicle_class   vehicle_class NOT NULL,
+    amount_cents    INTEGER NOT NULL CHECK (amount_cents > 0),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (from_zone_id, to_zone_id, vehicle_class)
+);
+
+CREATE TRIGGER trg_zone_rates_updated_at
+    BEFORE UPDATE ON zone_rates
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS zone_rates;
+DROP TABLE IF EXISTS service_areas;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'area_kind') THEN
+DROP TYPE area_kind;
+END IF;
+END$$;
+-- +goose StatementEnd
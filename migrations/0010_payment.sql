@@ -0,0 +1,74 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'payment_intent_status') THEN
+        CREATE TYPE payment_intent_status AS ENUM ('requires_capture', 'succeeded', 'canceled', 'failed');
+    END IF;
+END$$;
+
+-- Tokenized cards saved with the provider; we only keep display details and provider ids.
+-- Removed cards are soft-deleted so past payment intents keep their reference.
+CREATE TABLE payment_methods (
+    id                    UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id               UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider              VARCHAR(32) NOT NULL,
+    provider_customer_id  VARCHAR(255) NOT NULL,
+    provider_method_id    VARCHAR(255) NOT NULL,
+    brand                 VARCHAR(32) NOT NULL,
+    last4                 CHAR(4) NOT NULL,
+    exp_month             SMALLINT NOT NULL CHECK (exp_month BETWEEN 1 AND 12),
+    exp_year              SMALLINT NOT NULL,
+    is_default            BOOLEAN NOT NULL DEFAULT false,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    removed_at            TIMESTAMPTZ,
+    UNIQUE (provider, provider_method_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_methods_user ON payment_methods(user_id) WHERE removed_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS payment_methods_one_default
+    ON payment_methods(user_id) WHERE is_default AND removed_at IS NULL;
+
+-- booking_id has no foreign key yet: bookings are not modelled in this schema.
+CREATE TABLE payment_intents (
+    id                       UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id                  UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    booking_id               UUID,
+    payment_method_id        UUID NOT NULL REFERENCES payment_methods(id) ON DELETE RESTRICT,
+    provider                 VARCHAR(32) NOT NULL,
+    provider_intent_id       VARCHAR(255),
+    status                   payment_intent_status NOT NULL,
+    currency                 CHAR(3) NOT NULL DEFAULT 'usd',
+    amount_authorized_cents  BIGINT NOT NULL CHECK (amount_authorized_cents > 0),
+    amount_captured_cents    BIGINT NOT NULL DEFAULT 0 CHECK (amount_captured_cents >= 0),
+    amount_refunded_cents    BIGINT NOT NULL DEFAULT 0 CHECK (amount_refunded_cents >= 0),
+    failure_reason           TEXT,
+    created_at               TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at               TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (amount_captured_cents <= amount_authorized_cents),
+    CHECK (amount_refunded_cents <= amount_captured_cents)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS payment_intents_provider_id
+    ON payment_intents(provider, provider_intent_id) WHERE provider_intent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_payment_intents_user ON payment_intents(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payment_intents_booking ON payment_intents(booking_id) WHERE booking_id IS NOT NULL;
+
+CREATE TRIGGER trg_payment_intents_updated_at
+    BEFORE UPDATE ON payment_intents
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS payment_intents;
+DROP TABLE IF EXISTS payment_methods;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'payment_intent_status') THEN
+DROP TYPE payment_intent_status;
+END IF;
+END$$;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Stored responses for client-supplied Idempotency-Key headers, scoped per user.
+-- A row with completed_at NULL is a request still being processed.
+CREATE TABLE idempotency_keys (
+    user_id          UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idem_key         VARCHAR(255) NOT NULL,
+    method           VARCHAR(10) NOT NULL,
+    path             TEXT NOT NULL,
+    request_hash     CHAR(64) NOT NULL,
+    response_status  SMALLINT,
+    content_type     VARCHAR(255),
+    response_body    BYTEA,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at     TIMESTAMPTZ,
+    expires_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, idem_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Raw provider webhook events, kept for auditing and replay. processed_at stays NULL until a
+-- handler has applied the event successfully.
+CREATE TABLE payment_events (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider      VARCHAR(32) NOT NULL,
+    event_id      VARCHAR(255) NOT NULL,
+    event_type    VARCHAR(100) NOT NULL,
+    payload       JSONB NOT NULL,
+    received_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at  TIMESTAMPTZ,
+    attempts      INTEGER NOT NULL DEFAULT 0,
+    last_error    TEXT,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_events_unprocessed ON payment_events(received_at) WHERE processed_at IS NULL;
+
+ALTER TABLE payment_intents ADD COLUMN disputed_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE payment_intents DROP COLUMN IF EXISTS disputed_at;
+DROP TABLE IF EXISTS payment_events;
+-- +goose StatementEnd
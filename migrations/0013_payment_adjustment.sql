@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'adjustment_kind') THEN
+        CREATE TYPE adjustment_kind AS ENUM ('refund', 'charge');
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'adjustment_reason') THEN
+        CREATE TYPE adjustment_reason AS ENUM (
+            'service_issue', 'overcharge', 'goodwill', 'toll', 'parking', 'damage', 'cleaning', 'other'
+        );
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'adjustment_status') THEN
+        CREATE TYPE adjustment_status AS ENUM ('pending_approval', 'processing', 'applied', 'rejected', 'failed');
+    END IF;
+END$$;
+
+-- Post-trip line items against a payment: refunds go back on the original intent, extra charges
+-- (tolls, parking, damage/cleaning fees) are taken as a new intent on the same card.
+CREATE TABLE payment_adjustments (
+    id                 UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payment_intent_id  UUID NOT NULL REFERENCES payment_intents(id) ON DELETE RESTRICT,
+    kind               adjustment_kind NOT NULL,
+    reason             adjustment_reason NOT NULL,
+    amount_cents       BIGINT NOT NULL CHECK (amount_cents > 0),
+    note               TEXT,
+    status             adjustment_status NOT NULL,
+    requested_by       UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    decided_by         UUID REFERENCES users(id) ON DELETE RESTRICT,
+    decided_at         TIMESTAMPTZ,
+    decision_note      TEXT,
+    provider_ref       VARCHAR(255),
+    charge_intent_id   UUID REFERENCES payment_intents(id) ON DELETE RESTRICT,
+    failure_reason     TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (decided_by IS NULL OR decided_by <> requested_by)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_adjustments_intent ON payment_adjustments(payment_intent_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_payment_adjustments_pending ON payment_adjustments(created_at) WHERE status = 'pending_approval';
+
+CREATE TRIGGER trg_payment_adjustments_updated_at
+    BEFORE UPDATE ON payment_adjustments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS payment_adjustments;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'adjustment_status') THEN
+DROP TYPE adjustment_status;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'adjustment_reason') THEN
+DROP TYPE adjustment_reason;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'adjustment_kind') THEN
+DROP TYPE adjustment_kind;
+END IF;
+END$$;
+-- +goose StatementEnd
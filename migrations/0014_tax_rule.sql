@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'tax_kind') THEN
+        CREATE TYPE tax_kind AS ENUM ('percentage', 'flat');
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'tax_trip_end') THEN
+        CREATE TYPE tax_trip_end AS ENUM ('pickup', 'dropoff', 'either');
+    END IF;
+END$$;
+
+-- Taxes and fees levied by a jurisdiction. The jurisdiction is a service area or zone (a city, an
+-- airport); applies_on says which end of the trip has to fall inside it. Percentages are in basis
+-- points of the fare. effective_to is exclusive and NULL means open-ended.
+CREATE TABLE tax_rules (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    area_id         UUID NOT NULL REFERENCES service_areas(id) ON DELETE CASCADE,
+    code            VARCHAR(50) NOT NULL,
+    name            VARCHAR(255) NOT NULL,
+    kind            tax_kind NOT NULL,
+    rate_bps        INTEGER NOT NULL DEFAULT 0,
+    amount_cents    INTEGER NOT NULL DEFAULT 0,
+    applies_on      tax_trip_end NOT NULL DEFAULT 'pickup',
+    effective_from  DATE NOT NULL,
+    effective_to    DATE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((kind = 'percentage' AND rate_bps > 0 AND rate_bps <= 10000 AND amount_cents = 0)
+        OR (kind = 'flat' AND amount_cents > 0 AND rate_bps = 0)),
+    CHECK (effective_to IS NULL OR effective_to > effective_from),
+    -- One version of a given tax per jurisdiction at any date.
+    CONSTRAINT tax_rules_no_overlap EXCLUDE USING gist (
+        area_id WITH =, code WITH =, daterange(effective_from, effective_to) WITH &&
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_rules_area ON tax_rules(area_id);
+
+CREATE TRIGGER trg_tax_rules_updated_at
+    BEFORE UPDATE ON tax_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tax_rules;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'tax_trip_end') THEN
+DROP TYPE tax_trip_end;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'tax_kind') THEN
+DROP TYPE tax_kind;
+END IF;
+END$$;
+-- +goose StatementEnd
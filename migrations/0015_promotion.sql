@@ -0,0 +1,98 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'promo_kind') THEN
+        CREATE TYPE promo_kind AS ENUM ('percentage', 'fixed');
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'promo_redemption_status') THEN
+        CREATE TYPE promo_redemption_status AS ENUM ('applied', 'reversed');
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'credit_entry_kind') THEN
+        CREATE TYPE credit_entry_kind AS ENUM ('grant', 'correction', 'spend', 'restore');
+    END IF;
+END$$;
+
+-- Codes are stored upper-case. NULL eligibility lists mean "any"; redemption_count is maintained
+-- under a row lock so max_redemptions can't be overshot by concurrent bookings.
+CREATE TABLE promo_codes (
+    id                  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code                VARCHAR(50) NOT NULL UNIQUE,
+    description         TEXT,
+    kind                promo_kind NOT NULL,
+    percent_bps         INTEGER NOT NULL DEFAULT 0,
+    amount_cents        INTEGER NOT NULL DEFAULT 0,
+    max_discount_cents  INTEGER CHECK (max_discount_cents > 0),
+    min_fare_cents      INTEGER NOT NULL DEFAULT 0 CHECK (min_fare_cents >= 0),
+    vehicle_classes     TEXT[] CHECK (vehicle_classes <@ ARRAY['escalade', 'suburban', 'sprinter']),
+    zone_ids            UUID[],
+    first_ride_only     BOOLEAN NOT NULL DEFAULT false,
+    starts_at           TIMESTAMPTZ NOT NULL,
+    ends_at             TIMESTAMPTZ,
+    max_redemptions     INTEGER CHECK (max_redemptions > 0),
+    max_per_user        INTEGER NOT NULL DEFAULT 1 CHECK (max_per_user > 0),
+    redemption_count    INTEGER NOT NULL DEFAULT 0 CHECK (redemption_count >= 0),
+    active              BOOLEAN NOT NULL DEFAULT true,
+    created_by          UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((kind = 'percentage' AND percent_bps > 0 AND percent_bps <= 10000 AND amount_cents = 0)
+        OR (kind = 'fixed' AND amount_cents > 0 AND percent_bps = 0)),
+    CHECK (ends_at IS NULL OR ends_at > starts_at),
+    CHECK (max_redemptions IS NULL OR redemption_count <= max_redemptions)
+);
+
+CREATE TRIGGER trg_promo_codes_updated_at
+    BEFORE UPDATE ON promo_codes
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- booking_id has no foreign key yet: bookings are not modelled in this schema.
+CREATE TABLE promo_redemptions (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promo_id        UUID NOT NULL REFERENCES promo_codes(id) ON DELETE RESTRICT,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id      UUID,
+    fare_cents      BIGINT NOT NULL CHECK (fare_cents >= 0),
+    discount_cents  BIGINT NOT NULL CHECK (discount_cents >= 0),
+    status          promo_redemption_status NOT NULL DEFAULT 'applied',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reversed_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_promo_user ON promo_redemptions(promo_id, user_id) WHERE status = 'applied';
+
+-- Append-only credit ledger; a user's balance is the sum of their entries and never goes negative.
+CREATE TABLE credit_entries (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind          credit_entry_kind NOT NULL,
+    amount_cents  BIGINT NOT NULL CHECK (amount_cents <> 0),
+    reference_id  UUID,
+    note          TEXT,
+    created_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_entries_user ON credit_entries(user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS credit_entries;
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'credit_entry_kind') THEN
+DROP TYPE credit_entry_kind;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'promo_redemption_status') THEN
+DROP TYPE promo_redemption_status;
+END IF;
+  IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'promo_kind') THEN
+DROP TYPE promo_kind;
+END IF;
+END$$;
+-- +goose StatementEnd
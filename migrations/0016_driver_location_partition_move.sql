@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Rows that landed in the default partition before their month's partition existed would make a plain
+-- CREATE ... PARTITION OF fail, so the month is built as a standalone table, filled with those rows and
+-- then attached. The default partition is locked for the move so no new row for the month slips in.
+CREATE OR REPLACE FUNCTION ensure_driver_locations_partition(at TIMESTAMPTZ)
+RETURNS void AS $$
+DECLARE
+    month_start DATE := date_trunc('month', at AT TIME ZONE 'UTC')::date;
+    part_name   TEXT := 'driver_locations_' || to_char(month_start, 'YYYYMM');
+    range_from  TIMESTAMPTZ := month_start::timestamp AT TIME ZONE 'UTC';
+    range_to    TIMESTAMPTZ := (month_start + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC';
+BEGIN
+    IF to_regclass(part_name) IS NOT NULL THEN
+        RETURN;
+    END IF;
+
+    LOCK TABLE driver_locations_default IN ACCESS EXCLUSIVE MODE;
+    EXECUTE format('CREATE TABLE %I (LIKE driver_locations INCLUDING DEFAULTS INCLUDING CONSTRAINTS)', part_name);
+    EXECUTE format(
+        'WITH moved AS (
+            DELETE FROM driver_locations_default WHERE recorded_at >= %L AND recorded_at < %L RETURNING *
+        ) INSERT INTO %I SELECT * FROM moved',
+        range_from, range_to, part_name
+    );
+    EXECUTE format(
+        'ALTER TABLE driver_locations ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+        part_name, range_from, range_to
+    );
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION ensure_driver_locations_partition(at TIMESTAMPTZ)
+RETURNS void AS $$
+DECLARE
+    month_start DATE := date_trunc('month', at AT TIME ZONE 'UTC')::date;
+    part_name   TEXT := 'driver_locations_' || to_char(month_start, 'YYYYMM');
+BEGIN
+    IF to_regclass(part_name) IS NULL THEN
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF driver_locations FOR VALUES FROM (%L) TO (%L)',
+            part_name,
+            (month_start::timestamp AT TIME ZONE 'UTC'),
+            ((month_start + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC')
+        );
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd